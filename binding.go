@@ -0,0 +1,39 @@
+package simple
+
+import (
+	"reflect"
+
+	"github.com/hehexianshi/simple/binding"
+)
+
+// 按 Content-Type 把请求解码成 obj 的类型并校验
+// 结果和 binding.Errors 一起注入给后面的 handler
+// obj 为指针时注入指针, 否则注入值, binding 标签写错时在这里就 panic
+//
+//	m.Post("/contact", Bind(ContactForm{}), func(form ContactForm, errs binding.Errors) {})
+func Bind(obj interface{}) Handler {
+	typ := reflect.TypeOf(obj)
+	isPtr := typ.Kind() == reflect.Ptr
+	if isPtr {
+		typ = typ.Elem()
+	}
+
+	if typ.Kind() != reflect.Struct {
+		panic("bind object must be a struct or a pointer to struct")
+	}
+	if err := binding.CheckRules(obj); err != nil {
+		panic("bind: " + err.Error())
+	}
+
+	return Provides(func(ctx *Context) {
+		val := reflect.New(typ)
+		errs := binding.Bind(ctx.Req.Request, val.Interface())
+
+		ctx.Map(errs)
+		if isPtr {
+			ctx.Map(val.Interface())
+		} else {
+			ctx.Map(val.Elem().Interface())
+		}
+	}, binding.Errors{}, obj)
+}
@@ -0,0 +1,262 @@
+// 把请求数据解码到结构体, 并按 binding 标签校验
+//
+//	type ContactForm struct {
+//		Name    string `form:"name" json:"name" binding:"Required;MaxSize(50)"`
+//		Email   string `form:"email" json:"email" binding:"Required;Email"`
+//		Message string `form:"message" json:"message" binding:"Required"`
+//	}
+package binding
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// 解析 multipart 表单时最多使用的内存, 超过的部分写到临时文件
+// Context 的 GetFile 和表单方法也使用这个值
+var MaxMemory = int64(1024 * 1024 * 10)
+
+// 结构体实现这个接口时, 标签校验之后还会调用 Validate
+type Validator interface {
+	Validate(*http.Request, Errors) Errors
+}
+
+// 根据请求方法和 Content-Type 选择解码方式
+// obj 必须是结构体指针
+func Bind(req *http.Request, obj interface{}) Errors {
+	return bindWith(req, obj, decode)
+}
+
+// 和 Bind 一样, 只是不校验, 可以先填充别的字段再调用 Validate
+func Decode(req *http.Request, obj interface{}) Errors {
+	errs, _ := decode(req, obj)
+	return errs
+}
+
+func decode(req *http.Request, obj interface{}) (Errors, bool) {
+	contentType := req.Header.Get("Content-Type")
+	if req.Method == "GET" || req.Method == "HEAD" || req.Method == "DELETE" || len(contentType) == 0 {
+		return decodeForm(req, obj)
+	}
+
+	switch {
+	case strings.Contains(contentType, "form-urlencoded"):
+		return decodeForm(req, obj)
+	case strings.Contains(contentType, "multipart/form-data"):
+		return decodeMultipartForm(req, obj)
+	case strings.Contains(contentType, "json"):
+		return decodeJSON(req, obj)
+	case strings.Contains(contentType, "xml"):
+		return decodeXML(req, obj)
+	}
+
+	var errs Errors
+	errs.Add([]string{}, ERR_CONTENT_TYPE, "Unsupported Content-Type")
+	return errs, false
+}
+
+// 解码失败时不再校验
+func bindWith(req *http.Request, obj interface{}, decode func(*http.Request, interface{}) (Errors, bool)) Errors {
+	errs, ok := decode(req, obj)
+	if !ok {
+		return errs
+	}
+	return Validate(req, obj, errs)
+}
+
+// 查询参数和 urlencoded 表单
+func Form(req *http.Request, obj interface{}) Errors {
+	return bindWith(req, obj, decodeForm)
+}
+
+func MultipartForm(req *http.Request, obj interface{}) Errors {
+	return bindWith(req, obj, decodeMultipartForm)
+}
+
+func JSON(req *http.Request, obj interface{}) Errors {
+	return bindWith(req, obj, decodeJSON)
+}
+
+func XML(req *http.Request, obj interface{}) Errors {
+	return bindWith(req, obj, decodeXML)
+}
+
+func decodeForm(req *http.Request, obj interface{}) (Errors, bool) {
+	var errs Errors
+	if err := req.ParseForm(); err != nil {
+		errs.Add([]string{}, ERR_DESERIALIZATION, err.Error())
+		return errs, false
+	}
+
+	mapForm(reflect.ValueOf(obj).Elem(), req.Form, nil, &errs)
+	return errs, true
+}
+
+func decodeMultipartForm(req *http.Request, obj interface{}) (Errors, bool) {
+	var errs Errors
+	if req.MultipartForm == nil {
+		if err := req.ParseMultipartForm(MaxMemory); err != nil {
+			errs.Add([]string{}, ERR_DESERIALIZATION, err.Error())
+			return errs, false
+		}
+	}
+
+	mapForm(reflect.ValueOf(obj).Elem(), req.MultipartForm.Value, req.MultipartForm.File, &errs)
+	return errs, true
+}
+
+func decodeJSON(req *http.Request, obj interface{}) (Errors, bool) {
+	var errs Errors
+	if req.Body != nil {
+		defer req.Body.Close()
+		if err := json.NewDecoder(req.Body).Decode(obj); err != nil && err != io.EOF {
+			errs.Add([]string{}, ERR_DESERIALIZATION, err.Error())
+			return errs, false
+		}
+	}
+	return errs, true
+}
+
+func decodeXML(req *http.Request, obj interface{}) (Errors, bool) {
+	var errs Errors
+	if req.Body != nil {
+		defer req.Body.Close()
+		if err := xml.NewDecoder(req.Body).Decode(obj); err != nil && err != io.EOF {
+			errs.Add([]string{}, ERR_DESERIALIZATION, err.Error())
+			return errs, false
+		}
+	}
+	return errs, true
+}
+
+// 按 form 标签把 form 里的值填到 obj, 不校验, 例如路由参数
+func MapForm(obj interface{}, form map[string][]string) Errors {
+	var errs Errors
+	mapForm(reflect.ValueOf(obj).Elem(), form, nil, &errs)
+	return errs
+}
+
+// 校验标签, 再调用 Validator
+func Validate(req *http.Request, obj interface{}, errs Errors) Errors {
+	errs = validateStruct(reflect.ValueOf(obj), errs)
+	if v, ok := obj.(Validator); ok {
+		errs = v.Validate(req, errs)
+	}
+	return errs
+}
+
+var (
+	fileHeaderType      = reflect.TypeOf((*multipart.FileHeader)(nil))
+	fileHeaderSliceType = reflect.TypeOf([]*multipart.FileHeader(nil))
+)
+
+// 字段名取 form 标签, 没有时用字段名, form:"-" 跳过
+// 匿名结构体的字段当作自己的字段
+func mapForm(formStruct reflect.Value, form map[string][]string, files map[string][]*multipart.FileHeader, errs *Errors) {
+	typ := formStruct.Type()
+
+	for i := 0; i < typ.NumField(); i++ {
+		typeField := typ.Field(i)
+		structField := formStruct.Field(i)
+
+		if typeField.Anonymous && typeField.Type.Kind() == reflect.Struct {
+			mapForm(structField, form, files, errs)
+			continue
+		}
+
+		name := typeField.Tag.Get("form")
+		if name == "-" || !structField.CanSet() {
+			continue
+		}
+		if len(name) == 0 {
+			name = typeField.Name
+		}
+
+		switch typeField.Type {
+		case fileHeaderType:
+			if fhs := files[name]; len(fhs) > 0 {
+				structField.Set(reflect.ValueOf(fhs[0]))
+			}
+			continue
+		case fileHeaderSliceType:
+			if fhs := files[name]; len(fhs) > 0 {
+				structField.Set(reflect.ValueOf(fhs))
+			}
+			continue
+		}
+
+		inputValue, exists := form[name]
+		if !exists || len(inputValue) == 0 {
+			continue
+		}
+
+		if structField.Kind() == reflect.Slice {
+			slice := reflect.MakeSlice(structField.Type(), len(inputValue), len(inputValue))
+			for j := 0; j < len(inputValue); j++ {
+				setWithProperType(structField.Type().Elem().Kind(), inputValue[j], slice.Index(j), name, errs)
+			}
+			structField.Set(slice)
+		} else {
+			setWithProperType(typeField.Type.Kind(), inputValue[0], structField, name, errs)
+		}
+	}
+}
+
+func setWithProperType(kind reflect.Kind, val string, field reflect.Value, name string, errs *Errors) {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if len(val) == 0 {
+			val = "0"
+		}
+		i, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			errs.Add([]string{name}, ERR_INTERGER_TYPE, "Value could not be parsed as integer")
+		} else {
+			field.SetInt(i)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if len(val) == 0 {
+			val = "0"
+		}
+		i, err := strconv.ParseUint(val, 10, 64)
+		if err != nil {
+			errs.Add([]string{name}, ERR_INTERGER_TYPE, "Value could not be parsed as unsigned integer")
+		} else {
+			field.SetUint(i)
+		}
+	case reflect.Float32, reflect.Float64:
+		if len(val) == 0 {
+			val = "0"
+		}
+		f, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			errs.Add([]string{name}, ERR_FLOAT_TYPE, "Value could not be parsed as float")
+		} else {
+			field.SetFloat(f)
+		}
+	case reflect.Bool:
+		// 复选框选中时的值为 on
+		if val == "on" {
+			field.SetBool(true)
+			break
+		}
+
+		if len(val) == 0 {
+			val = "false"
+		}
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			errs.Add([]string{name}, ERR_BOOLEAN_TYPE, "Value could not be parsed as boolean")
+		} else {
+			field.SetBool(b)
+		}
+	case reflect.String:
+		field.SetString(val)
+	}
+}
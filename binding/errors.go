@@ -0,0 +1,65 @@
+package binding
+
+const (
+	ERR_REQUIRED        = "RequiredError"
+	ERR_ALPHA_DASH      = "AlphaDashError"
+	ERR_ALPHA_DASH_DOT  = "AlphaDashDotError"
+	ERR_SIZE            = "SizeError"
+	ERR_MIN_SIZE        = "MinSizeError"
+	ERR_MAX_SIZE        = "MaxSizeError"
+	ERR_RANGE           = "RangeError"
+	ERR_EMAIL           = "EmailError"
+	ERR_URL             = "UrlError"
+	ERR_IN              = "InError"
+	ERR_NOT_IN          = "NotInError"
+	ERR_INCLUDE         = "IncludeError"
+	ERR_EXCLUDE         = "ExcludeError"
+	ERR_CONTENT_TYPE    = "ContentTypeError"
+	ERR_DESERIALIZATION = "DeserializationError"
+	ERR_INTERGER_TYPE   = "IntegerTypeError"
+	ERR_BOOLEAN_TYPE    = "BooleanTypeError"
+	ERR_FLOAT_TYPE      = "FloatTypeError"
+)
+
+type Error struct {
+	// 出错的字段, 和请求里的名字一致
+	FieldNames     []string `json:"fieldNames,omitempty"`
+	Classification string   `json:"classification,omitempty"`
+	Message        string   `json:"message,omitempty"`
+}
+
+func (e Error) Fields() []string {
+	return e.FieldNames
+}
+
+func (e Error) Kind() string {
+	return e.Classification
+}
+
+func (e Error) Error() string {
+	return e.Message
+}
+
+type Errors []Error
+
+func (e *Errors) Add(fieldNames []string, classification, message string) {
+	*e = append(*e, Error{
+		FieldNames:     fieldNames,
+		Classification: classification,
+		Message:        message,
+	})
+}
+
+func (e *Errors) Len() int {
+	return len(*e)
+}
+
+// 是否有某一类错误
+func (e *Errors) Has(class string) bool {
+	for _, err := range *e {
+		if err.Kind() == class {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,327 @@
+package binding
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	alphaDashPattern    = regexp.MustCompile(`[^\w-]`)
+	alphaDashDotPattern = regexp.MustCompile(`[^\w-.]`)
+	emailPattern        = regexp.MustCompile(`^[\w.%+-]+@[\w-]+(\.[\w-]+)*\.[a-zA-Z]{2,}$`)
+)
+
+// 嵌套的结构体也会校验
+func validateStruct(obj reflect.Value, errs Errors) Errors {
+	for obj.Kind() == reflect.Ptr {
+		if obj.IsNil() {
+			return errs
+		}
+		obj = obj.Elem()
+	}
+	if obj.Kind() != reflect.Struct {
+		return errs
+	}
+
+	typ := obj.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if len(field.PkgPath) > 0 && !field.Anonymous {
+			continue
+		}
+
+		fieldVal := obj.Field(i)
+		if fieldVal.Kind() == reflect.Struct || (fieldVal.Kind() == reflect.Ptr && fieldVal.Type().Elem().Kind() == reflect.Struct) {
+			errs = validateStruct(fieldVal, errs)
+		}
+
+		rules := field.Tag.Get("binding")
+		if len(rules) == 0 || rules == "-" {
+			continue
+		}
+		errs = validateField(field, fieldVal, rules, errs)
+	}
+	return errs
+}
+
+// 检查 obj 的类型里所有的 binding 标签, 规则名或者参数写错时返回错误
+// 应该在注册 handler 时调用, 不要等到处理请求时才 panic
+func CheckRules(obj interface{}) error {
+	return checkStruct(reflect.TypeOf(obj), make(map[reflect.Type]bool))
+}
+
+func checkStruct(typ reflect.Type, checked map[reflect.Type]bool) error {
+	for typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct || checked[typ] {
+		return nil
+	}
+	checked[typ] = true
+
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if len(field.PkgPath) > 0 && !field.Anonymous {
+			continue
+		}
+
+		if err := checkStruct(field.Type, checked); err != nil {
+			return err
+		}
+
+		rules := field.Tag.Get("binding")
+		if len(rules) == 0 || rules == "-" {
+			continue
+		}
+		for _, rule := range strings.Split(rules, ";") {
+			if rule = strings.TrimSpace(rule); len(rule) == 0 {
+				continue
+			}
+			if err := checkRule(rule); err != nil {
+				return fmt.Errorf("%s.%s: %v", typ, field.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// 和 validateField 支持的规则一致
+func checkRule(rule string) error {
+	name, args := parseRule(rule)
+	switch name {
+	case "Required", "AlphaDash", "AlphaDashDot", "Email", "Url":
+		return nil
+	case "Size", "MinSize", "MaxSize":
+		_, err := parseArgInt(rule, args, 0)
+		return err
+	case "Range":
+		if _, err := parseArgFloat(rule, args, 0); err != nil {
+			return err
+		}
+		_, err := parseArgFloat(rule, args, 1)
+		return err
+	case "In", "NotIn", "Include", "Exclude":
+		if len(args) == 0 {
+			return fmt.Errorf("missing argument for binding rule: %s", rule)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown binding rule: %s", rule)
+}
+
+// 错误里的字段名和请求里的保持一致
+func fieldName(field reflect.StructField) string {
+	if name := field.Tag.Get("form"); len(name) > 0 && name != "-" {
+		return name
+	}
+	if name := strings.Split(field.Tag.Get("json"), ",")[0]; len(name) > 0 && name != "-" {
+		return name
+	}
+	return field.Name
+}
+
+// Required;MaxSize(50);Email
+// 除了 Required, 其它规则在字段为空时不校验
+func validateField(field reflect.StructField, fieldVal reflect.Value, rules string, errs Errors) Errors {
+	names := []string{fieldName(field)}
+
+	for _, rule := range strings.Split(rules, ";") {
+		rule = strings.TrimSpace(rule)
+		if len(rule) == 0 {
+			continue
+		}
+
+		if rule == "Required" {
+			if isEmpty(fieldVal) {
+				errs.Add(names, ERR_REQUIRED, "Required")
+				return errs
+			}
+			continue
+		}
+
+		if isEmpty(fieldVal) {
+			continue
+		}
+
+		name, args := parseRule(rule)
+		switch name {
+		case "AlphaDash":
+			if alphaDashPattern.MatchString(fmt.Sprint(fieldVal.Interface())) {
+				errs.Add(names, ERR_ALPHA_DASH, "AlphaDash")
+				return errs
+			}
+		case "AlphaDashDot":
+			if alphaDashDotPattern.MatchString(fmt.Sprint(fieldVal.Interface())) {
+				errs.Add(names, ERR_ALPHA_DASH_DOT, "AlphaDashDot")
+				return errs
+			}
+		case "Size":
+			if size := argInt(rule, args, 0); valueLen(fieldVal) != size {
+				errs.Add(names, ERR_SIZE, "Size")
+				return errs
+			}
+		case "MinSize":
+			if min := argInt(rule, args, 0); valueLen(fieldVal) < min {
+				errs.Add(names, ERR_MIN_SIZE, "MinSize")
+				return errs
+			}
+		case "MaxSize":
+			if max := argInt(rule, args, 0); valueLen(fieldVal) > max {
+				errs.Add(names, ERR_MAX_SIZE, "MaxSize")
+				return errs
+			}
+		case "Range":
+			min, max := argFloat(rule, args, 0), argFloat(rule, args, 1)
+			if val, ok := valueFloat(fieldVal); !ok || val < min || val > max {
+				errs.Add(names, ERR_RANGE, "Range")
+				return errs
+			}
+		case "Email":
+			if !emailPattern.MatchString(fmt.Sprint(fieldVal.Interface())) {
+				errs.Add(names, ERR_EMAIL, "Email")
+				return errs
+			}
+		case "Url":
+			u, err := url.ParseRequestURI(fmt.Sprint(fieldVal.Interface()))
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+				errs.Add(names, ERR_URL, "Url")
+				return errs
+			}
+		case "In":
+			for _, val := range valueStrings(fieldVal) {
+				if !inArgs(val, args) {
+					errs.Add(names, ERR_IN, "In")
+					return errs
+				}
+			}
+		case "NotIn":
+			for _, val := range valueStrings(fieldVal) {
+				if inArgs(val, args) {
+					errs.Add(names, ERR_NOT_IN, "NotIn")
+					return errs
+				}
+			}
+		case "Include":
+			if !strings.Contains(fmt.Sprint(fieldVal.Interface()), strings.Join(args, ",")) {
+				errs.Add(names, ERR_INCLUDE, "Include")
+				return errs
+			}
+		case "Exclude":
+			if strings.Contains(fmt.Sprint(fieldVal.Interface()), strings.Join(args, ",")) {
+				errs.Add(names, ERR_EXCLUDE, "Exclude")
+				return errs
+			}
+		default:
+			panic("unknown binding rule: " + rule)
+		}
+	}
+	return errs
+}
+
+// MaxSize(50) => MaxSize, [50]
+func parseRule(rule string) (string, []string) {
+	i := strings.Index(rule, "(")
+	if i == -1 || !strings.HasSuffix(rule, ")") {
+		return rule, nil
+	}
+	return rule[:i], strings.Split(rule[i+1:len(rule)-1], ",")
+}
+
+func argInt(rule string, args []string, i int) int {
+	n, err := parseArgInt(rule, args, i)
+	if err != nil {
+		panic(err.Error())
+	}
+	return n
+}
+
+func argFloat(rule string, args []string, i int) float64 {
+	f, err := parseArgFloat(rule, args, i)
+	if err != nil {
+		panic(err.Error())
+	}
+	return f
+}
+
+func parseArgInt(rule string, args []string, i int) (int, error) {
+	if i >= len(args) {
+		return 0, fmt.Errorf("missing argument for binding rule: %s", rule)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(args[i]))
+	if err != nil {
+		return 0, fmt.Errorf("invalid argument for binding rule: %s", rule)
+	}
+	return n, nil
+}
+
+func parseArgFloat(rule string, args []string, i int) (float64, error) {
+	if i >= len(args) {
+		return 0, fmt.Errorf("missing argument for binding rule: %s", rule)
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(args[i]), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid argument for binding rule: %s", rule)
+	}
+	return f, nil
+}
+
+func inArgs(val string, args []string) bool {
+	for _, arg := range args {
+		if val == arg {
+			return true
+		}
+	}
+	return false
+}
+
+// 切片的每个元素都要校验
+func valueStrings(v reflect.Value) []string {
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return []string{fmt.Sprint(v.Interface())}
+	}
+
+	vals := make([]string, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		vals[i] = fmt.Sprint(v.Index(i).Interface())
+	}
+	return vals
+}
+
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+// 字符串按字符数算
+func valueLen(v reflect.Value) int {
+	switch v.Kind() {
+	case reflect.String:
+		return utf8.RuneCountInString(v.String())
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len()
+	}
+	return len(fmt.Sprint(v.Interface()))
+}
+
+func valueFloat(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	case reflect.String:
+		f, err := strconv.ParseFloat(v.String(), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
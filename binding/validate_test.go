@@ -0,0 +1,57 @@
+package binding
+
+import (
+	"testing"
+)
+
+type checkAddress struct {
+	City string `binding:"Required;MaxSize(20)"`
+}
+
+type checkUser struct {
+	Name    string `binding:"Required;AlphaDash;Size(4)"`
+	Age     int    `binding:"Range(1,150)"`
+	Role    string `binding:"In(admin,user)"`
+	Address *checkAddress
+	Parent  *checkUser
+}
+
+func TestCheckRules(t *testing.T) {
+	if err := CheckRules(checkUser{}); err != nil {
+		t.Errorf("valid rules: %v", err)
+	}
+	if err := CheckRules(&checkUser{}); err != nil {
+		t.Errorf("valid rules through pointer: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		obj  interface{}
+	}{
+		{"unknown rule", struct {
+			Name string `binding:"Requried"`
+		}{}},
+		{"missing int argument", struct {
+			Name string `binding:"MaxSize"`
+		}{}},
+		{"invalid int argument", struct {
+			Name string `binding:"MinSize(a)"`
+		}{}},
+		{"missing range bound", struct {
+			Age int `binding:"Range(1)"`
+		}{}},
+		{"missing in arguments", struct {
+			Role string `binding:"In"`
+		}{}},
+		{"nested struct", struct {
+			Address struct {
+				City string `binding:"Required;Emial"`
+			}
+		}{}},
+	}
+	for _, test := range tests {
+		if err := CheckRules(test.obj); err == nil {
+			t.Errorf("%s: CheckRules returned nil", test.name)
+		}
+	}
+}
@@ -0,0 +1,359 @@
+package simple
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 应用配置, 支持 .ini, .toml 和 .json 文件
+// 分区的 key 用 section.key 访问, 例如 database.host, 没有分区的直接用 key
+//
+// 以当前环境名开头的分区会覆盖对应的分区, 例如 Env 为 production 时
+// [production.database] 覆盖 [database], [production] 覆盖没有分区的 key
+// development, production, test 和当前的 Env 是保留的分区名, 不能当作普通分区使用,
+// 这些分区以及以它们加点开头的分区只用来覆盖, 其他环境的直接丢掉
+//
+// 环境变量的优先级最高, SIMPLE_CFG_DATABASE_HOST 覆盖 database.host
+// 变量名为 SIMPLE_CFG_ 加上大写的 key, 点和横线换成下划线
+type Config struct {
+	sections map[string]map[string]string
+}
+
+// 后面的文件覆盖前面的, 相对路径基于 Root
+func NewConfig(files ...string) (*Config, error) {
+	c := &Config{sections: map[string]map[string]string{"": {}}}
+	for _, name := range files {
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(Root, name)
+		}
+
+		if err := c.load(name); err != nil {
+			return nil, fmt.Errorf("config(%s): %v", name, err)
+		}
+	}
+
+	// 先把环境的分区都拿出来, 遍历的时候不能改 c.sections
+	env := safeEnv()
+	var overrides []string
+	envSections := make(map[string]map[string]string)
+	for section, kv := range c.sections {
+		for _, e := range []string{env, DEV, PROD, TEST} {
+			if section != e && !strings.HasPrefix(section, e+".") {
+				continue
+			}
+
+			if e == env {
+				overrides = append(overrides, section)
+				envSections[section] = kv
+			}
+			delete(c.sections, section)
+			break
+		}
+	}
+
+	sort.Strings(overrides)
+	for _, section := range overrides {
+		c.merge(strings.TrimPrefix(strings.TrimPrefix(section, env), "."), envSections[section])
+	}
+	return c, nil
+}
+
+// 加载配置文件并注入, handler 可以直接使用 *Config 参数
+func (m *Simple) LoadConfig(files ...string) (*Config, error) {
+	c, err := NewConfig(files...)
+	if err != nil {
+		return nil, err
+	}
+
+	m.Map(c)
+	return c, nil
+}
+
+func (c *Config) load(name string) error {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return err
+	}
+
+	var sections map[string]map[string]string
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".ini":
+		sections, err = parseINI(data)
+	case ".toml":
+		sections, err = parseTOML(data)
+	case ".json":
+		sections, err = parseConfigJSON(data)
+	default:
+		return fmt.Errorf("unsupported config format: %s", filepath.Ext(name))
+	}
+	if err != nil {
+		return err
+	}
+
+	for section, kv := range sections {
+		c.merge(section, kv)
+	}
+	return nil
+}
+
+func (c *Config) merge(section string, kv map[string]string) {
+	if c.sections[section] == nil {
+		c.sections[section] = make(map[string]string)
+	}
+	for k, v := range kv {
+		c.sections[section][k] = v
+	}
+}
+
+// database.host 分成 database 和 host
+func splitConfigKey(key string) (string, string) {
+	if i := strings.LastIndex(key, "."); i != -1 {
+		return key[:i], key[i+1:]
+	}
+	return "", key
+}
+
+func configEnvName(key string) string {
+	return "SIMPLE_CFG_" + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(key))
+}
+
+func (c *Config) lookup(key string) (string, bool) {
+	if val, ok := os.LookupEnv(configEnvName(key)); ok {
+		return val, true
+	}
+
+	section, name := splitConfigKey(key)
+	val, ok := c.sections[section][name]
+	return val, ok
+}
+
+func (c *Config) Has(key string) bool {
+	_, ok := c.lookup(key)
+	return ok
+}
+
+// 不存在时返回 defaultVal, 没有给出时返回空
+func (c *Config) Get(key string, defaultVal ...string) string {
+	if val, ok := c.lookup(key); ok {
+		return val
+	}
+
+	if len(defaultVal) > 0 {
+		return defaultVal[0]
+	}
+	return ""
+}
+
+func (c *Config) GetInt(key string, defaultVal ...int) int {
+	val, err := strconv.Atoi(c.Get(key))
+	if err != nil && len(defaultVal) > 0 {
+		return defaultVal[0]
+	}
+	return val
+}
+
+func (c *Config) GetInt64(key string, defaultVal ...int64) int64 {
+	val, err := strconv.ParseInt(c.Get(key), 10, 64)
+	if err != nil && len(defaultVal) > 0 {
+		return defaultVal[0]
+	}
+	return val
+}
+
+func (c *Config) GetFloat64(key string, defaultVal ...float64) float64 {
+	val, err := strconv.ParseFloat(c.Get(key), 64)
+	if err != nil && len(defaultVal) > 0 {
+		return defaultVal[0]
+	}
+	return val
+}
+
+func (c *Config) GetBool(key string, defaultVal ...bool) bool {
+	val, err := strconv.ParseBool(c.Get(key))
+	if err != nil && len(defaultVal) > 0 {
+		return defaultVal[0]
+	}
+	return val
+}
+
+// 格式和 time.ParseDuration 一样, 例如 30s
+func (c *Config) GetDuration(key string, defaultVal ...time.Duration) time.Duration {
+	val, err := time.ParseDuration(c.Get(key))
+	if err != nil && len(defaultVal) > 0 {
+		return defaultVal[0]
+	}
+	return val
+}
+
+// 逗号分隔的值, 数组也是这样保存的
+func (c *Config) GetStrings(key string) []string {
+	val := c.Get(key)
+	if len(val) == 0 {
+		return nil
+	}
+
+	vals := strings.Split(val, ",")
+	for i := range vals {
+		vals[i] = strings.TrimSpace(vals[i])
+	}
+	return vals
+}
+
+// 返回一个分区的所有 key, 已经应用了环境变量
+func (c *Config) Section(name string) map[string]string {
+	kv := make(map[string]string, len(c.sections[name]))
+	for k := range c.sections[name] {
+		key := k
+		if len(name) > 0 {
+			key = name + "." + k
+		}
+		kv[k], _ = c.lookup(key)
+	}
+	return kv
+}
+
+// 嵌套的对象变成分区, {"database": {"host": "..."}} 对应 database.host
+func parseConfigJSON(data []byte) (map[string]map[string]string, error) {
+	var obj map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&obj); err != nil {
+		return nil, err
+	}
+
+	sections := map[string]map[string]string{"": {}}
+	var walk func(section string, obj map[string]interface{})
+	walk = func(section string, obj map[string]interface{}) {
+		if sections[section] == nil {
+			sections[section] = make(map[string]string)
+		}
+
+		for k, v := range obj {
+			switch v := v.(type) {
+			case map[string]interface{}:
+				if len(section) > 0 {
+					k = section + "." + k
+				}
+				walk(k, v)
+			case []interface{}:
+				vals := make([]string, len(v))
+				for i := range v {
+					vals[i] = fmt.Sprint(v[i])
+				}
+				sections[section][k] = strings.Join(vals, ",")
+			case nil:
+				sections[section][k] = ""
+			default:
+				sections[section][k] = fmt.Sprint(v)
+			}
+		}
+	}
+	walk("", obj)
+	return sections, nil
+}
+
+// 只支持常用的部分: [section], key = value, 字符串, 数字, 布尔值和单行的数组
+// 数组保存为逗号分隔的字符串
+func parseTOML(data []byte) (map[string]map[string]string, error) {
+	sections := map[string]map[string]string{"": {}}
+	section := ""
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+
+		if line[0] == '[' {
+			end := strings.Index(line, "]")
+			if end == -1 || strings.HasPrefix(line, "[[") {
+				return nil, fmt.Errorf("line %d: unsupported table: %s", n, line)
+			}
+
+			section = strings.TrimSpace(line[1:end])
+			if _, ok := sections[section]; !ok {
+				sections[section] = make(map[string]string)
+			}
+			continue
+		}
+
+		i := strings.Index(line, "=")
+		if i == -1 {
+			return nil, fmt.Errorf("line %d: missing '=': %s", n, line)
+		}
+
+		key := strings.Trim(strings.TrimSpace(line[:i]), `"`)
+		val, err := parseTOMLValue(strings.TrimSpace(line[i+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		sections[section][key] = val
+	}
+	return sections, scanner.Err()
+}
+
+func parseTOMLValue(s string) (string, error) {
+	if len(s) == 0 {
+		return "", fmt.Errorf("missing value")
+	}
+
+	switch s[0] {
+	case '"':
+		end := 1
+		for ; end < len(s); end++ {
+			if s[end] == '\\' {
+				end++
+			} else if s[end] == '"' {
+				break
+			}
+		}
+		if end >= len(s) {
+			return "", fmt.Errorf("unterminated string: %s", s)
+		}
+		return strconv.Unquote(s[:end+1])
+	case '\'':
+		end := strings.Index(s[1:], "'")
+		if end == -1 {
+			return "", fmt.Errorf("unterminated string: %s", s)
+		}
+		return s[1 : end+1], nil
+	case '[':
+		end := strings.LastIndex(s, "]")
+		if end == -1 {
+			return "", fmt.Errorf("unterminated array: %s", s)
+		}
+
+		var vals []string
+		for _, item := range strings.Split(s[1:end], ",") {
+			item = strings.TrimSpace(item)
+			if len(item) == 0 {
+				continue
+			}
+
+			val, err := parseTOMLValue(item)
+			if err != nil {
+				return "", err
+			}
+			vals = append(vals, val)
+		}
+		return strings.Join(vals, ","), nil
+	case '{':
+		return "", fmt.Errorf("inline table is not supported: %s", s)
+	}
+
+	// 数字, 布尔值和日期, 去掉行尾注释
+	if i := strings.Index(s, "#"); i != -1 {
+		s = strings.TrimSpace(s[:i])
+	}
+	return strings.Replace(s, "_", "", -1), nil
+}
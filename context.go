@@ -1,13 +1,19 @@
 package simple
 
 import (
+	"html/template"
+	"io"
+	"mime/multipart"
 	"net/http"
+	"net/url"
+	"os"
 	"reflect"
+	"strconv"
 	"strings"
 
-	"fmt"
-
+	"github.com/Unknwon/com"
 	"github.com/go-macaron/inject"
+	"github.com/hehexianshi/simple/binding"
 )
 
 type Context struct {
@@ -17,9 +23,13 @@ type Context struct {
 	index    int
 
 	*Router
-	Req    Request
-	Resp   ResponseWriter
-	params Params
+	Req     Request
+	Resp    ResponseWriter
+	params  Params
+	pattern string
+	// 解析过的查询参数和对应的 RawQuery
+	query    url.Values
+	queryRaw string
 	Render
 	Locale
 	Data map[string]interface{}
@@ -51,7 +61,6 @@ func (c *Context) Written() bool {
 // use 之后 c.handlers
 // 任何中间件 都是 handler
 func (c *Context) run() {
-	fmt.Println(c.params)
 	for c.index <= len(c.handlers) {
 		vals, err := c.Invoke(c.handler())
 
@@ -72,6 +81,29 @@ func (c *Context) run() {
 	}
 }
 
+// 替换当前请求, 之后注入的 *http.Request, Query 的缓存和 TplRender 用的请求都换成新的
+func (c *Context) setRequest(req *http.Request) {
+	c.Req.Request = req
+	c.query = nil
+	c.Map(req)
+
+	if r, ok := c.Render.(*TplRender); ok {
+		r.req = req
+	}
+}
+
+// 替换响应, 注入的 http.ResponseWriter 和 Render 一起换掉
+func (c *Context) setResponseWriter(rw ResponseWriter) {
+	c.Resp = rw
+	c.MapTo(rw, (*http.ResponseWriter)(nil))
+
+	if r, ok := c.Render.(*DummyRender); ok {
+		r.ResponseWriter = rw
+	} else if c.Render != nil {
+		c.Render.SetResponseWrite(rw)
+	}
+}
+
 type Request struct {
 	*http.Request
 }
@@ -108,9 +140,166 @@ func (ctx *Context) Params(name string) string {
 		return ""
 	}
 
-	if len(name) > 1 && name[0] != ':' {
+	return ctx.params[paramName(name)]
+}
+
+// id => :id, 通配符 * 和 *0 不变
+func paramName(name string) string {
+	if name[0] != ':' && name[0] != '*' {
 		name = ":" + name
 	}
+	return name
+}
+
+func (ctx *Context) ParamsEscape(name string) string {
+	return template.HTMLEscapeString(ctx.Params(name))
+}
+
+func (ctx *Context) ParamsInt(name string) int {
+	return com.StrTo(ctx.Params(name)).MustInt()
+}
+
+func (ctx *Context) ParamsInt64(name string) int64 {
+	return com.StrTo(ctx.Params(name)).MustInt64()
+}
+
+func (ctx *Context) ParamsFloat64(name string) float64 {
+	v, _ := strconv.ParseFloat(ctx.Params(name), 64)
+	return v
+}
+
+func (ctx *Context) SetParams(name, val string) {
+	if len(name) == 0 {
+		return
+	}
+
+	if ctx.params == nil {
+		ctx.params = make(Params)
+	}
+	ctx.params[paramName(name)] = val
+}
+
+// 查询参数没变时只解析一次, 请求被替换或者 URL 被修改后重新解析
+func (ctx *Context) queryValues() url.Values {
+	if ctx.query == nil || ctx.queryRaw != ctx.Req.URL.RawQuery {
+		ctx.query = ctx.Req.URL.Query()
+		ctx.queryRaw = ctx.Req.URL.RawQuery
+	}
+	return ctx.query
+}
+
+func (ctx *Context) Query(name string) string {
+	return ctx.queryValues().Get(name)
+}
+
+func (ctx *Context) QueryTrim(name string) string {
+	return strings.TrimSpace(ctx.Query(name))
+}
+
+func (ctx *Context) QueryStrings(name string) []string {
+	vals, ok := ctx.queryValues()[name]
+	if !ok {
+		return []string{}
+	}
+	return vals
+}
+
+func (ctx *Context) QueryEscape(name string) string {
+	return template.HTMLEscapeString(ctx.Query(name))
+}
+
+func (ctx *Context) QueryInt(name string) int {
+	return com.StrTo(ctx.Query(name)).MustInt()
+}
+
+func (ctx *Context) QueryInt64(name string) int64 {
+	return com.StrTo(ctx.Query(name)).MustInt64()
+}
+
+func (ctx *Context) QueryFloat64(name string) float64 {
+	v, _ := strconv.ParseFloat(ctx.Query(name), 64)
+	return v
+}
+
+func (ctx *Context) QueryBool(name string) bool {
+	v, _ := strconv.ParseBool(ctx.Query(name))
+	return v
+}
+
+// 只取请求体里的表单, 不包含查询参数
+func (ctx *Context) postForm() url.Values {
+	if ctx.Req.PostForm == nil {
+		if strings.Contains(ctx.Req.Header.Get("Content-Type"), "multipart/form-data") {
+			ctx.Req.ParseMultipartForm(binding.MaxMemory)
+		} else {
+			ctx.Req.ParseForm()
+		}
+	}
+	return ctx.Req.PostForm
+}
+
+func (ctx *Context) Form(name string) string {
+	return ctx.postForm().Get(name)
+}
+
+func (ctx *Context) FormTrim(name string) string {
+	return strings.TrimSpace(ctx.Form(name))
+}
+
+func (ctx *Context) FormStrings(name string) []string {
+	vals, ok := ctx.postForm()[name]
+	if !ok {
+		return []string{}
+	}
+	return vals
+}
+
+func (ctx *Context) FormEscape(name string) string {
+	return template.HTMLEscapeString(ctx.Form(name))
+}
+
+func (ctx *Context) FormInt(name string) int {
+	return com.StrTo(ctx.Form(name)).MustInt()
+}
+
+func (ctx *Context) FormInt64(name string) int64 {
+	return com.StrTo(ctx.Form(name)).MustInt64()
+}
+
+func (ctx *Context) FormFloat64(name string) float64 {
+	v, _ := strconv.ParseFloat(ctx.Form(name), 64)
+	return v
+}
+
+func (ctx *Context) FormBool(name string) bool {
+	v, _ := strconv.ParseBool(ctx.Form(name))
+	return v
+}
+
+// 上传的文件, 使用 binding.MaxMemory 解析表单
+func (ctx *Context) GetFile(name string) (multipart.File, *multipart.FileHeader, error) {
+	if ctx.Req.MultipartForm == nil {
+		if err := ctx.Req.ParseMultipartForm(binding.MaxMemory); err != nil {
+			return nil, nil, err
+		}
+	}
+	return ctx.Req.FormFile(name)
+}
+
+// 把上传的文件保存到 savePath
+func (ctx *Context) SaveToFile(name, savePath string) error {
+	fr, _, err := ctx.GetFile(name)
+	if err != nil {
+		return err
+	}
+	defer fr.Close()
+
+	fw, err := os.OpenFile(savePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer fw.Close()
 
-	return ctx.params[name]
+	_, err = io.Copy(fw, fr)
+	return err
 }
@@ -0,0 +1,233 @@
+package simple
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Unknwon/com"
+)
+
+// SecureCookie 和 SuperSecureCookie 使用的密钥
+func (m *Simple) SetDefaultCookieSecret(secret string) {
+	m.cookieSecret = secret
+}
+
+// others 依次为 MaxAge, Path, Domain, Secure, HttpOnly, Expires
+// ctx.SetCookie("name", "value", 3600, "/", "", false, true)
+func (ctx *Context) SetCookie(name string, value string, others ...interface{}) {
+	cookie := http.Cookie{}
+	cookie.Name = name
+	cookie.Value = url.QueryEscape(value)
+
+	if len(others) > 0 {
+		cookie.MaxAge = cookieMaxAge(others[0])
+	}
+
+	cookie.Path = "/"
+	if len(others) > 1 {
+		if v, ok := others[1].(string); ok && len(v) > 0 {
+			cookie.Path = v
+		}
+	}
+
+	if len(others) > 2 {
+		if v, ok := others[2].(string); ok && len(v) > 0 {
+			cookie.Domain = v
+		}
+	}
+
+	if len(others) > 3 {
+		if v, ok := others[3].(bool); ok {
+			cookie.Secure = v
+		}
+	}
+
+	if len(others) > 4 {
+		if v, ok := others[4].(bool); ok {
+			cookie.HttpOnly = v
+		}
+	}
+
+	if len(others) > 5 {
+		if v, ok := others[5].(time.Time); ok {
+			cookie.Expires = v
+		}
+	}
+
+	ctx.Resp.Header().Add("Set-Cookie", cookie.String())
+}
+
+func cookieMaxAge(v interface{}) int {
+	switch v := v.(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case int32:
+		return int(v)
+	}
+	return 0
+}
+
+// 过期时间写进值里, 0 表示会话 cookie
+func cookieExpires(others []interface{}) int64 {
+	if len(others) == 0 {
+		return 0
+	}
+
+	if maxAge := cookieMaxAge(others[0]); maxAge > 0 {
+		return time.Now().Add(time.Duration(maxAge) * time.Second).Unix()
+	}
+	return 0
+}
+
+func cookieExpired(expires int64) bool {
+	return expires > 0 && time.Now().Unix() > expires
+}
+
+func (ctx *Context) GetCookie(name string) string {
+	cookie, err := ctx.Req.Cookie(name)
+	if err != nil {
+		return ""
+	}
+
+	val, _ := url.QueryUnescape(cookie.Value)
+	return val
+}
+
+func (ctx *Context) GetCookieInt(name string) int {
+	return com.StrTo(ctx.GetCookie(name)).MustInt()
+}
+
+func (ctx *Context) GetCookieInt64(name string) int64 {
+	return com.StrTo(ctx.GetCookie(name)).MustInt64()
+}
+
+func (ctx *Context) GetCookieFloat64(name string) float64 {
+	v, _ := strconv.ParseFloat(ctx.GetCookie(name), 64)
+	return v
+}
+
+func cookieSignature(secret, name, payload string) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(name + "|" + payload))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// 值为 base64(value)|过期时间|签名, 签名包含 cookie 名, 不能挪给别的 cookie 用
+// 只防篡改, 不加密
+func (ctx *Context) SetSecureCookie(name, value string, others ...interface{}) {
+	secret := ctx.Router.m.cookieSecret
+	if len(secret) == 0 {
+		panic("cookie secret hasn't been set")
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString([]byte(value)) + "|" + strconv.FormatInt(cookieExpires(others), 10)
+	ctx.SetCookie(name, payload+"|"+cookieSignature(secret, name, payload), others...)
+}
+
+func (ctx *Context) GetSecureCookie(name string) (string, bool) {
+	secret := ctx.Router.m.cookieSecret
+	if len(secret) == 0 {
+		return "", false
+	}
+
+	parts := strings.Split(ctx.GetCookie(name), "|")
+	if len(parts) != 3 {
+		return "", false
+	}
+
+	payload := parts[0] + "|" + parts[1]
+	if !hmac.Equal([]byte(parts[2]), []byte(cookieSignature(secret, name, payload))) {
+		return "", false
+	}
+
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || cookieExpired(expires) {
+		return "", false
+	}
+
+	val, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", false
+	}
+	return string(val), true
+}
+
+// 密钥从 SetDefaultCookieSecret 设置的密钥派生, 和签名用的密钥不同
+func newCookieCipher(secret string) (cipher.AEAD, error) {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte("super secure cookie"))
+	block, err := aes.NewCipher(h.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// AES-GCM 加密, 过期时间和值一起加密, cookie 名作为附加数据
+func (ctx *Context) SetSuperSecureCookie(name, value string, others ...interface{}) {
+	secret := ctx.Router.m.cookieSecret
+	if len(secret) == 0 {
+		panic("cookie secret hasn't been set")
+	}
+
+	aead, err := newCookieCipher(secret)
+	if err != nil {
+		panic("fail to create cookie cipher: " + err.Error())
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		panic("fail to generate cookie nonce: " + err.Error())
+	}
+
+	plain := strconv.FormatInt(cookieExpires(others), 10) + "|" + value
+	sealed := aead.Seal(nonce, nonce, []byte(plain), []byte(name))
+	ctx.SetCookie(name, base64.RawURLEncoding.EncodeToString(sealed), others...)
+}
+
+func (ctx *Context) GetSuperSecureCookie(name string) (string, bool) {
+	secret := ctx.Router.m.cookieSecret
+	if len(secret) == 0 {
+		return "", false
+	}
+
+	sealed, err := base64.RawURLEncoding.DecodeString(ctx.GetCookie(name))
+	if err != nil {
+		return "", false
+	}
+
+	aead, err := newCookieCipher(secret)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", false
+	}
+
+	nonce, sealed := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, sealed, []byte(name))
+	if err != nil {
+		return "", false
+	}
+
+	parts := strings.SplitN(string(plain), "|", 2)
+	if len(parts) != 2 {
+		return "", false
+	}
+
+	expires, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || cookieExpired(expires) {
+		return "", false
+	}
+	return parts[1], true
+}
@@ -0,0 +1,133 @@
+package simple
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"html/template"
+	"net/http"
+
+	"github.com/hehexianshi/simple/session"
+)
+
+type CsrfOptions struct {
+	// session 里保存 token 的 key, 默认 _csrf_token
+	SessionKey string
+	// 表单字段名, 默认 _csrf
+	Form string
+	// 请求头, 默认 X-CSRFToken
+	Header string
+	// 是否在响应头里带上 token, 给 ajax 用
+	SetHeader bool
+	// 校验失败时调用, 默认返回 400, 没有写响应时也返回 400, 后面的 handler 不会执行
+	ErrorFunc func(*Context)
+}
+
+func prepareCsrfOptions(options []CsrfOptions) CsrfOptions {
+	var opt CsrfOptions
+	if len(options) > 0 {
+		opt = options[0]
+	}
+
+	if len(opt.SessionKey) == 0 {
+		opt.SessionKey = "_csrf_token"
+	}
+	if len(opt.Form) == 0 {
+		opt.Form = "_csrf"
+	}
+	if len(opt.Header) == 0 {
+		opt.Header = "X-CSRFToken"
+	}
+	if opt.ErrorFunc == nil {
+		opt.ErrorFunc = func(ctx *Context) {
+			http.Error(ctx.Resp, "Bad Request: invalid csrf token", http.StatusBadRequest)
+		}
+	}
+	return opt
+}
+
+type CSRF interface {
+	GetHeaderName() string
+	GetFormName() string
+	GetToken() string
+	ValidToken(string) bool
+	Error(*Context)
+}
+
+type csrf struct {
+	opt   *CsrfOptions
+	token string
+}
+
+func (c *csrf) GetHeaderName() string {
+	return c.opt.Header
+}
+
+func (c *csrf) GetFormName() string {
+	return c.opt.Form
+}
+
+func (c *csrf) GetToken() string {
+	return c.token
+}
+
+func (c *csrf) ValidToken(t string) bool {
+	return len(t) > 0 && subtle.ConstantTimeCompare([]byte(t), []byte(c.token)) == 1
+}
+
+func (c *csrf) Error(ctx *Context) {
+	c.opt.ErrorFunc(ctx)
+}
+
+func generateCsrfToken() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic("fail to generate csrf token: " + err.Error())
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// 需要先使用 Sessioner, 每个 session 一个 token
+// 模板里用 {{.CsrfTokenHtml}} 输出隐藏字段, 或者 {{.CsrfToken}}
+func Csrfer(options ...CsrfOptions) Handler {
+	opt := prepareCsrfOptions(options)
+
+	return Provides(func(ctx *Context, sess session.Store) {
+		token, _ := sess.Get(opt.SessionKey).(string)
+		if len(token) == 0 {
+			token = generateCsrfToken()
+			sess.Set(opt.SessionKey, token)
+		}
+
+		x := &csrf{&opt, token}
+		ctx.MapTo(x, (*CSRF)(nil))
+
+		ctx.Data["CsrfToken"] = token
+		ctx.Data["CsrfTokenHtml"] = template.HTML(`<input type="hidden" name="` + template.HTMLEscapeString(opt.Form) + `" value="` + token + `">`)
+		if opt.SetHeader {
+			ctx.Resp.Header().Set(opt.Header, token)
+		}
+	}, (*CSRF)(nil))
+}
+
+// 非安全方法必须在请求头或者表单里带上正确的 token
+// m.Post("/login", ValidateCsrf, ...) 或者 m.Use(ValidateCsrf)
+func ValidateCsrf(ctx *Context, x CSRF) {
+	switch ctx.Req.Method {
+	case "GET", "HEAD", "OPTIONS", "TRACE":
+		return
+	}
+
+	token := ctx.Req.Header.Get(x.GetHeaderName())
+	if len(token) == 0 {
+		token = ctx.Form(x.GetFormName())
+	}
+
+	if !x.ValidToken(token) {
+		x.Error(ctx)
+		// ErrorFunc 只记录日志的时候也不能继续执行
+		if !ctx.Written() {
+			http.Error(ctx.Resp, "Bad Request: invalid csrf token", http.StatusBadRequest)
+		}
+	}
+}
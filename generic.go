@@ -0,0 +1,134 @@
+package simple
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/hehexianshi/simple/binding"
+)
+
+// 类型安全的 handler, 签名在编译时检查, 不经过 inject
+// In 从路由参数和请求内容解码并校验, 和 Bind 一样使用 form, json 和 binding 标签
+// 路由参数按 form 标签填充, 优先于请求内容
+// 返回的 Out 用 Render 编码成 JSON, handler 自己写了响应时不再编码
+// 解码或者校验失败返回 400 和 binding.Errors, 返回 error 时交给 InternalServerError
+//
+//	type UserQuery struct {
+//		ID int64 `form:"id" binding:"Required"`
+//	}
+//	GetT(m.Router, "/users/:id", func(ctx *Context, q UserQuery) (*User, error) {})
+func HandleT[In, Out any](r *Router, method, pattern string, h func(*Context, In) (Out, error)) *Route {
+	if h == nil {
+		panic("typed handler cannot be nil")
+	}
+
+	inType := reflect.TypeOf((*In)(nil)).Elem()
+	structType := inType
+	isPtr := inType.Kind() == reflect.Ptr
+	if isPtr {
+		structType = inType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("typed handler input must be a struct or a pointer to struct: %s", inType))
+	}
+	if err := binding.CheckRules(reflect.New(structType).Interface()); err != nil {
+		panic("typed handler: " + err.Error())
+	}
+
+	outType := reflect.TypeOf((*Out)(nil)).Elem()
+	switch outType.Kind() {
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer, reflect.Complex64, reflect.Complex128:
+		panic(fmt.Sprintf("typed handler output cannot be encoded as JSON: %s", outType))
+	}
+
+	// 没有使用 Renderer 时用默认配置编码
+	opt := prepareRenderOptions(nil)
+
+	return r.Handle(method, pattern, []Handler{func(ctx *Context) {
+		render := ctx.Render
+		if _, ok := render.(*DummyRender); ok || render == nil {
+			render = &TplRender{
+				ResponseWriter:  ctx.Resp,
+				req:             ctx.Req.Request,
+				opt:             &opt,
+				compiledCharset: "; charset=" + opt.Charset,
+			}
+		}
+
+		val := reflect.New(structType)
+		errs := binding.Decode(ctx.Req.Request, val.Interface())
+		if !errs.Has(binding.ERR_DESERIALIZATION) && !errs.Has(binding.ERR_CONTENT_TYPE) {
+			errs = append(errs, binding.MapForm(val.Interface(), paramsForm(ctx.params))...)
+			errs = binding.Validate(ctx.Req.Request, val.Interface(), errs)
+		}
+		if errs.Len() > 0 {
+			render.JSON(http.StatusBadRequest, firstErrorPerField(errs))
+			return
+		}
+
+		var in In
+		if isPtr {
+			in = val.Interface().(In)
+		} else {
+			in = val.Elem().Interface().(In)
+		}
+
+		out, err := h(ctx, in)
+		if err != nil {
+			ctx.internalServerError(ctx, err)
+			return
+		}
+
+		if !ctx.Written() {
+			render.JSON(http.StatusOK, out)
+		}
+	}})
+}
+
+// 路由参数去掉 ':' 之后作为表单字段名
+func paramsForm(params Params) map[string][]string {
+	form := make(map[string][]string, len(params))
+	for k, v := range params {
+		form[strings.TrimPrefix(k, ":")] = []string{v}
+	}
+	return form
+}
+
+// 解码和校验都可能给同一个字段报错, 例如类型错误之后又报 Required, 只保留第一个
+// 没有字段的错误都保留
+func firstErrorPerField(errs binding.Errors) binding.Errors {
+	seen := make(map[string]bool, len(errs))
+	result := make(binding.Errors, 0, len(errs))
+	for _, err := range errs {
+		if key := strings.Join(err.FieldNames, ","); len(key) > 0 {
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+		}
+		result = append(result, err)
+	}
+	return result
+}
+
+func GetT[In, Out any](r *Router, pattern string, h func(*Context, In) (Out, error)) *Route {
+	return HandleT(r, "GET", pattern, h)
+}
+
+func PostT[In, Out any](r *Router, pattern string, h func(*Context, In) (Out, error)) *Route {
+	return HandleT(r, "POST", pattern, h)
+}
+
+func PutT[In, Out any](r *Router, pattern string, h func(*Context, In) (Out, error)) *Route {
+	return HandleT(r, "PUT", pattern, h)
+}
+
+func PatchT[In, Out any](r *Router, pattern string, h func(*Context, In) (Out, error)) *Route {
+	return HandleT(r, "PATCH", pattern, h)
+}
+
+func DeleteT[In, Out any](r *Router, pattern string, h func(*Context, In) (Out, error)) *Route {
+	return HandleT(r, "DELETE", pattern, h)
+}
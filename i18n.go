@@ -0,0 +1,375 @@
+package simple
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type I18nOptions struct {
+	// 语言文件目录, 相对路径基于 Root, 默认 conf/locale
+	// 文件名为 locale_zh-CN.ini 或者 zh-CN.json
+	Directory string
+	// 支持的语言, 为空时使用目录下所有的语言文件
+	Langs []string
+	// 语言的显示名, 和 Langs 一一对应, 默认和语言代码相同
+	Names []string
+	// 默认语言, 为空时取 Langs 的第一个
+	DefaultLang string
+	// url 参数名, 默认 lang
+	Parameter string
+	// 保存语言的 cookie 名, 默认 lang
+	CookieName string
+	// 默认 /
+	CookiePath string
+	// 为 true 时把没有语言前缀的 GET 和 HEAD 请求重定向到 /zh-CN/... 这样的地址
+	Redirect bool
+}
+
+func prepareI18nOptions(options []I18nOptions) I18nOptions {
+	var opt I18nOptions
+	if len(options) > 0 {
+		opt = options[0]
+	}
+
+	if len(opt.Directory) == 0 {
+		opt.Directory = "conf/locale"
+	}
+	if !filepath.IsAbs(opt.Directory) {
+		opt.Directory = filepath.Join(Root, opt.Directory)
+	}
+	if len(opt.Parameter) == 0 {
+		opt.Parameter = "lang"
+	}
+	if len(opt.CookieName) == 0 {
+		opt.CookieName = "lang"
+	}
+	if len(opt.CookiePath) == 0 {
+		opt.CookiePath = "/"
+	}
+	return opt
+}
+
+type LangType struct {
+	Lang, Name string
+}
+
+// 所有语言的翻译, 加载之后只读
+type localeStore struct {
+	langs       []LangType
+	messages    map[string]map[string]string
+	defaultLang string
+}
+
+func localeFileLang(name string) string {
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	return strings.TrimPrefix(name, "locale_")
+}
+
+func newLocaleStore(opt I18nOptions) (*localeStore, error) {
+	files, err := ioutil.ReadDir(opt.Directory)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &localeStore{messages: make(map[string]map[string]string)}
+	for _, fi := range files {
+		ext := strings.ToLower(filepath.Ext(fi.Name()))
+		if fi.IsDir() || (ext != ".ini" && ext != ".json") {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(opt.Directory, fi.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		var messages map[string]string
+		if ext == ".ini" {
+			messages, err = parseLocaleINI(data)
+		} else {
+			messages, err = parseLocaleJSON(data)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", fi.Name(), err)
+		}
+
+		lang := localeFileLang(fi.Name())
+		if s.messages[lang] == nil {
+			s.messages[lang] = messages
+		} else {
+			for k, v := range messages {
+				s.messages[lang][k] = v
+			}
+		}
+	}
+
+	langs := opt.Langs
+	if len(langs) == 0 {
+		for lang := range s.messages {
+			langs = append(langs, lang)
+		}
+		sort.Strings(langs)
+	}
+	if len(langs) == 0 {
+		return nil, fmt.Errorf("no locale file found in %s", opt.Directory)
+	}
+
+	for i, lang := range langs {
+		if _, ok := s.messages[lang]; !ok {
+			return nil, fmt.Errorf("locale file not found for language: %s", lang)
+		}
+
+		name := lang
+		if i < len(opt.Names) {
+			name = opt.Names[i]
+		}
+		s.langs = append(s.langs, LangType{lang, name})
+	}
+
+	s.defaultLang = opt.DefaultLang
+	if len(s.defaultLang) == 0 {
+		s.defaultLang = langs[0]
+	}
+	if s.match(s.defaultLang) != s.defaultLang {
+		return nil, fmt.Errorf("default language is not supported: %s", s.defaultLang)
+	}
+	return s, nil
+}
+
+// 分区里的 key 变成 section.key
+func parseLocaleINI(data []byte) (map[string]string, error) {
+	sections, err := parseINI(data)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make(map[string]string)
+	for section, kv := range sections {
+		for k, v := range kv {
+			if len(section) > 0 {
+				k = section + "." + k
+			}
+			messages[k] = v
+		}
+	}
+	return messages, nil
+}
+
+// 嵌套的对象变成 a.b.c
+func parseLocaleJSON(data []byte) (map[string]string, error) {
+	var obj map[string]interface{}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, err
+	}
+
+	messages := make(map[string]string)
+	var flatten func(prefix string, obj map[string]interface{})
+	flatten = func(prefix string, obj map[string]interface{}) {
+		for k, v := range obj {
+			if len(prefix) > 0 {
+				k = prefix + "." + k
+			}
+
+			switch v := v.(type) {
+			case map[string]interface{}:
+				flatten(k, v)
+			case string:
+				messages[k] = v
+			default:
+				messages[k] = fmt.Sprint(v)
+			}
+		}
+	}
+	flatten("", obj)
+	return messages, nil
+}
+
+// 返回支持的语言代码, 不区分大小写, 不支持时返回空
+// 只给了主语言时也能匹配, 例如 en 可以匹配 en-US
+func (s *localeStore) match(lang string) string {
+	if len(lang) == 0 {
+		return ""
+	}
+
+	for _, l := range s.langs {
+		if strings.EqualFold(l.Lang, lang) {
+			return l.Lang
+		}
+	}
+
+	base := strings.SplitN(lang, "-", 2)[0]
+	for _, l := range s.langs {
+		if strings.EqualFold(strings.SplitN(l.Lang, "-", 2)[0], base) {
+			return l.Lang
+		}
+	}
+	return ""
+}
+
+func (s *localeStore) name(lang string) string {
+	for _, l := range s.langs {
+		if l.Lang == lang {
+			return l.Name
+		}
+	}
+	return lang
+}
+
+// 按 q 值从高到低排, q 相同的保持原来的顺序
+func parseAcceptLanguage(header string) []string {
+	type langQ struct {
+		lang string
+		q    float64
+	}
+
+	var list []langQ
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if len(part) == 0 {
+			continue
+		}
+
+		lq := langQ{part, 1}
+		if i := strings.Index(part, ";"); i != -1 {
+			lq.lang = strings.TrimSpace(part[:i])
+			param := strings.TrimSpace(part[i+1:])
+			if strings.HasPrefix(param, "q=") {
+				q, err := strconv.ParseFloat(param[2:], 64)
+				if err != nil {
+					continue
+				}
+				lq.q = q
+			}
+		}
+		if lq.q <= 0 || lq.lang == "*" {
+			continue
+		}
+		list = append(list, lq)
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].q > list[j].q
+	})
+
+	langs := make([]string, len(list))
+	for i := range list {
+		langs[i] = list[i].lang
+	}
+	return langs
+}
+
+type locale struct {
+	store *localeStore
+	lang  string
+}
+
+func (l *locale) Language() string {
+	return l.lang
+}
+
+// 找不到时用默认语言, 还找不到就返回 key 本身
+func (l *locale) Tr(key string, args ...interface{}) string {
+	format, ok := l.store.messages[l.lang][key]
+	if !ok {
+		if format, ok = l.store.messages[l.store.defaultLang][key]; !ok {
+			format = key
+		}
+	}
+
+	if len(args) > 0 {
+		return fmt.Sprintf(format, args...)
+	}
+	return format
+}
+
+type urlLangKey struct{}
+
+// 语言的优先级: url 前缀, url 参数, cookie, Accept-Language, 默认语言
+// url 前缀在路由匹配之前去掉, /zh-CN/about 和 /about 匹配同一个路由
+// url 参数指定的语言会写到 cookie 里
+// 模板里用 {{.i18n.Tr "home.title"}} 或者 {{call .Tr "home.title"}}
+func I18n(options ...I18nOptions) Handler {
+	opt := prepareI18nOptions(options)
+	store, err := newLocaleStore(opt)
+	if err != nil {
+		panic("i18n: " + err.Error())
+	}
+
+	before := func(rw http.ResponseWriter, req *http.Request) *http.Request {
+		parts := strings.SplitN(strings.TrimPrefix(req.URL.Path, "/"), "/", 2)
+		lang := store.match(parts[0])
+		if len(lang) == 0 || !strings.EqualFold(lang, parts[0]) {
+			return req
+		}
+
+		// WithContext 只是浅拷贝, URL 也要复制一份
+		u := *req.URL
+		u.Path = "/"
+		if len(parts) > 1 {
+			u.Path += parts[1]
+		}
+		u.RawPath = ""
+		req = req.WithContext(context.WithValue(req.Context(), urlLangKey{}, lang))
+		req.URL = &u
+		return req
+	}
+
+	handler := Provides(func(ctx *Context) {
+		lang, hasPrefix := ctx.Req.Context().Value(urlLangKey{}).(string)
+
+		if len(lang) == 0 {
+			if lang = store.match(ctx.Query(opt.Parameter)); len(lang) > 0 {
+				ctx.SetCookie(opt.CookieName, lang, 1<<31-1, opt.CookiePath)
+			}
+		}
+		if len(lang) == 0 {
+			lang = store.match(ctx.GetCookie(opt.CookieName))
+		}
+		if len(lang) == 0 {
+			for _, l := range parseAcceptLanguage(ctx.Req.Header.Get("Accept-Language")) {
+				if lang = store.match(l); len(lang) > 0 {
+					break
+				}
+			}
+		}
+		if len(lang) == 0 {
+			lang = store.defaultLang
+		}
+
+		// 只重定向 GET 和 HEAD, 其他方法重定向之后会变成 GET
+		method := ctx.Req.Method
+		if opt.Redirect && !hasPrefix && (method == "GET" || method == "HEAD") {
+			query := ctx.Req.URL.Query()
+			query.Del(opt.Parameter)
+
+			target := "/" + lang + ctx.Req.URL.Path
+			if m := ctx.Router.m; m.hasURLPrefix {
+				target = m.urlPrefix + target
+			}
+			if len(query) > 0 {
+				target += "?" + query.Encode()
+			}
+			http.Redirect(ctx.Resp, ctx.Req.Request, target, http.StatusFound)
+			return
+		}
+
+		l := &locale{store, lang}
+		ctx.Locale = l
+		ctx.MapTo(l, (*Locale)(nil))
+
+		ctx.Data["Lang"] = lang
+		ctx.Data["LangName"] = store.name(lang)
+		ctx.Data["AllLangs"] = store.langs
+		ctx.Data["i18n"] = l
+		ctx.Data["Tr"] = l.Tr
+	}, (*Locale)(nil))
+
+	return &beforeHandler{handler, before}
+}
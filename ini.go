@@ -0,0 +1,56 @@
+package simple
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 简单的 INI 解析, 只支持分区和 key = value
+// ; 和 # 开头为注释, 双引号包起来的值会去掉引号
+// 没有分区的 key 放在 "" 分区里
+func parseINI(data []byte) (map[string]map[string]string, error) {
+	sections := map[string]map[string]string{"": {}}
+	section := ""
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if n == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+
+		if len(line) == 0 || line[0] == ';' || line[0] == '#' {
+			continue
+		}
+
+		if line[0] == '[' {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: invalid section: %s", n, line)
+			}
+
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			if _, ok := sections[section]; !ok {
+				sections[section] = make(map[string]string)
+			}
+			continue
+		}
+
+		i := strings.IndexAny(line, "=:")
+		if i == -1 {
+			return nil, fmt.Errorf("line %d: missing '=': %s", n, line)
+		}
+
+		key := strings.TrimSpace(line[:i])
+		val := strings.TrimSpace(line[i+1:])
+		if len(val) > 1 && val[0] == '"' && val[len(val)-1] == '"' {
+			if unquoted, err := strconv.Unquote(val); err == nil {
+				val = unquoted
+			}
+		}
+		sections[section][key] = val
+	}
+	return sections, scanner.Err()
+}
@@ -0,0 +1,137 @@
+package simple
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+
+	"github.com/Unknwon/com"
+)
+
+// systemd 传过来的第一个 fd
+const listenFdsStart = 3
+
+// 参数可以是:
+//   - net.Listener, 直接使用
+//   - host, port 或者 host 和 port, 没有给出的从环境变量 HOST, PORT 读取
+//   - "unix:/path/to/app.sock", 监听 unix socket
+//
+// 设置了 LISTEN_FDS 和 LISTEN_PID 时(systemd socket activation)使用传进来的 fd
+// 热重启启动的子进程使用父进程的监听
+func (m *Simple) listen(args ...interface{}) (net.Listener, error) {
+	for _, arg := range args {
+		if ln, ok := arg.(net.Listener); ok {
+			return ln, nil
+		}
+	}
+
+	if ln, err := inheritedListener(0); ln != nil || err != nil {
+		return ln, err
+	}
+
+	if ln, err := activationListener(); ln != nil || err != nil {
+		return ln, err
+	}
+
+	host, port := GetDefaultListenInfo()
+
+	if len(args) == 1 {
+		switch arg := args[0].(type) {
+		case string:
+			host = arg
+		case int:
+			port = arg
+		}
+	} else if len(args) >= 2 {
+		if arg, ok := args[0].(string); ok {
+			host = arg
+		}
+
+		if arg, ok := args[1].(int); ok {
+			port = arg
+		}
+	}
+
+	if strings.HasPrefix(host, "unix:") {
+		return m.listenUnix(strings.TrimPrefix(host, "unix:"))
+	}
+	return net.Listen("tcp", host+":"+com.ToStr(port))
+}
+
+func activationListener() (net.Listener, error) {
+	pid, _ := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	fds, _ := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if pid != os.Getpid() || fds == 0 {
+		return nil, nil
+	}
+
+	// 子进程不应该再使用
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	f := os.NewFile(listenFdsStart, "LISTEN_FD_"+strconv.Itoa(listenFdsStart))
+	defer f.Close()
+
+	ln, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("socket activation: %v", err)
+	}
+	return ln, nil
+}
+
+// 上次没有正常退出留下的 socket 文件会先删掉
+func (m *Simple) listenUnix(path string) (net.Listener, error) {
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		if err = os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = m.setSocketPerm(path); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+func (m *Simple) setSocketPerm(path string) error {
+	if m.serverOpt.SocketMode != 0 {
+		if err := os.Chmod(path, m.serverOpt.SocketMode); err != nil {
+			return err
+		}
+	}
+
+	if len(m.serverOpt.SocketUser) == 0 && len(m.serverOpt.SocketGroup) == 0 {
+		return nil
+	}
+
+	uid, gid := -1, -1
+	if len(m.serverOpt.SocketUser) > 0 {
+		u, err := user.Lookup(m.serverOpt.SocketUser)
+		if err != nil {
+			return err
+		}
+		if uid, err = strconv.Atoi(u.Uid); err != nil {
+			return err
+		}
+	}
+	if len(m.serverOpt.SocketGroup) > 0 {
+		g, err := user.LookupGroup(m.serverOpt.SocketGroup)
+		if err != nil {
+			return err
+		}
+		if gid, err = strconv.Atoi(g.Gid); err != nil {
+			return err
+		}
+	}
+	return os.Chown(path, uid, gid)
+}
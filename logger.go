@@ -1,10 +1,18 @@
 package simple
 
 import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"reflect"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -49,3 +57,216 @@ func (invoke LoggerInvoker) Invoke(params []interface{}) ([]reflect.Value, error
 	invoke(params[0].(*Context), params[1].(*log.Logger))
 	return nil, nil
 }
+
+const (
+	LogFieldTime       = "time"
+	LogFieldMethod     = "method"
+	LogFieldPath       = "path"
+	LogFieldPattern    = "pattern"
+	LogFieldStatus     = "status"
+	LogFieldSize       = "size"
+	LogFieldDuration   = "duration"
+	LogFieldRemoteAddr = "remote_addr"
+	LogFieldRequestID  = "request_id"
+)
+
+var defaultLogFields = []string{
+	LogFieldTime,
+	LogFieldMethod,
+	LogFieldPath,
+	LogFieldPattern,
+	LogFieldStatus,
+	LogFieldSize,
+	LogFieldDuration,
+	LogFieldRemoteAddr,
+	LogFieldRequestID,
+}
+
+// 每个请求一条记录
+type LogRecord struct {
+	Time       time.Time
+	Method     string
+	Path       string
+	Pattern    string
+	Status     int
+	Size       int
+	Duration   time.Duration
+	RemoteAddr string
+	RequestID  string
+}
+
+func (rec *LogRecord) Value(field string) interface{} {
+	switch field {
+	case LogFieldTime:
+		return rec.Time.Format(LogTimeFormat)
+	case LogFieldMethod:
+		return rec.Method
+	case LogFieldPath:
+		return rec.Path
+	case LogFieldPattern:
+		return rec.Pattern
+	case LogFieldStatus:
+		return rec.Status
+	case LogFieldSize:
+		return rec.Size
+	case LogFieldDuration:
+		return rec.Duration
+	case LogFieldRemoteAddr:
+		return rec.RemoteAddr
+	case LogFieldRequestID:
+		return rec.RequestID
+	}
+	return nil
+}
+
+// 按 fields 的顺序输出一行, 不包含换行符
+type LogFormatter func(rec *LogRecord, fields []string) []byte
+
+// 2006-01-02 15:04:05 GET /user/1 /user/:id 200 12 1.2ms 127.0.0.1 -
+func TextLogFormatter(rec *LogRecord, fields []string) []byte {
+	vals := make([]string, 0, len(fields))
+	for _, field := range fields {
+		val := fmt.Sprint(rec.Value(field))
+		if len(val) == 0 {
+			val = "-"
+		}
+		vals = append(vals, val)
+	}
+	return []byte(strings.Join(vals, " "))
+}
+
+// {"time":"2006-01-02 15:04:05","method":"GET",...}
+// duration 为纳秒
+func JSONLogFormatter(rec *LogRecord, fields []string) []byte {
+	buf := new(bytes.Buffer)
+	buf.WriteByte('{')
+	for i, field := range fields {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		key, _ := json.Marshal(field)
+		val, err := json.Marshal(rec.Value(field))
+		if err != nil {
+			val = []byte("null")
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(val)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes()
+}
+
+// time="2006-01-02 15:04:05" method=GET path=/user/1 ...
+func LogfmtLogFormatter(rec *LogRecord, fields []string) []byte {
+	buf := new(bytes.Buffer)
+	for i, field := range fields {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+
+		val := fmt.Sprint(rec.Value(field))
+		if len(val) == 0 || strings.ContainsAny(val, " =\"\t\n") {
+			val = strconv.Quote(val)
+		}
+		buf.WriteString(field)
+		buf.WriteByte('=')
+		buf.WriteString(val)
+	}
+	return buf.Bytes()
+}
+
+type LoggerOptions struct {
+	// 默认 TextLogFormatter
+	Formatter LogFormatter
+	// 输出哪些字段以及顺序, 默认全部
+	Fields []string
+	// 这些路径不记录日志
+	SkipPaths []string
+	// 为空时使用注入的 *log.Logger
+	Output io.Writer
+	// 请求没有带 request id 时会生成一个, 并写到响应头里
+	RequestIDHeader string
+}
+
+func prepareLoggerOptions(options []LoggerOptions) LoggerOptions {
+	var opt LoggerOptions
+	if len(options) > 0 {
+		opt = options[0]
+	}
+
+	if opt.Formatter == nil {
+		opt.Formatter = TextLogFormatter
+	}
+	if len(opt.Fields) == 0 {
+		opt.Fields = defaultLogFields
+	}
+	if len(opt.RequestIDHeader) == 0 {
+		opt.RequestIDHeader = "X-Request-Id"
+	}
+	return opt
+}
+
+func LoggerWithOptions(options ...LoggerOptions) Handler {
+	opt := prepareLoggerOptions(options)
+
+	skips := make(map[string]bool, len(opt.SkipPaths))
+	for _, p := range opt.SkipPaths {
+		skips[p] = true
+	}
+
+	var lock sync.Mutex
+	return func(ctx *Context, log *log.Logger) {
+		if skips[ctx.Req.URL.Path] {
+			ctx.Next()
+			return
+		}
+
+		start := time.Now()
+		requestID := ctx.Req.Header.Get(opt.RequestIDHeader)
+		if len(requestID) == 0 {
+			requestID = newRequestID()
+			ctx.Req.Header.Set(opt.RequestIDHeader, requestID)
+		}
+		ctx.Resp.Header().Set(opt.RequestIDHeader, requestID)
+
+		rw := ctx.Resp
+		ctx.Next()
+
+		status := rw.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		rec := &LogRecord{
+			Time:       start,
+			Method:     ctx.Req.Method,
+			Path:       ctx.Req.URL.Path,
+			Pattern:    ctx.pattern,
+			Status:     status,
+			Size:       rw.Size(),
+			Duration:   time.Since(start),
+			RemoteAddr: ctx.RemoteAddr(),
+			RequestID:  requestID,
+		}
+		line := opt.Formatter(rec, opt.Fields)
+
+		if opt.Output == nil {
+			log.Println(string(line))
+			return
+		}
+
+		lock.Lock()
+		defer lock.Unlock()
+		opt.Output.Write(append(line, '\n'))
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b)
+}
@@ -2,19 +2,78 @@ package simple
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"html/template"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"runtime"
+	"strings"
 
 	"github.com/go-macaron/inject"
 )
 
 const (
-	panicHtml = `<html></html>`
+	panicHtml = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>PANIC: {{.Error}}</title>
+<style>
+body { margin: 0; font-family: Menlo, Consolas, monospace; font-size: 13px; color: #333; background: #f5f5f5; }
+h1 { margin: 0; padding: 20px; color: #fff; background: #c0392b; font-size: 18px; word-wrap: break-word; }
+h2 { margin: 20px 20px 8px; font-size: 15px; }
+table { margin: 0 20px; border-collapse: collapse; }
+td { padding: 2px 10px 2px 0; vertical-align: top; }
+td.name { font-weight: bold; white-space: nowrap; }
+.frame { margin: 0 20px 10px; background: #fff; border: 1px solid #ddd; }
+.frame .func { padding: 6px 10px; background: #eee; }
+.frame .file { color: #888; }
+pre { margin: 0; padding: 6px 0; overflow: auto; }
+pre span { display: block; padding: 0 10px; }
+pre span.current { background: #fbe3e4; font-weight: bold; }
+pre span i { display: inline-block; width: 50px; color: #aaa; font-style: normal; }
+</style>
+</head>
+<body>
+<h1>PANIC: {{.Error}}</h1>
+
+<h2>Request</h2>
+<table>
+<tr><td class="name">Method</td><td>{{.Method}}</td></tr>
+<tr><td class="name">URL</td><td>{{.URL}}</td></tr>
+{{with .Pattern}}<tr><td class="name">Route</td><td>{{.}}</td></tr>{{end}}
+</table>
+
+{{if .Params}}
+<h2>Params</h2>
+<table>
+{{range $name, $value := .Params}}<tr><td class="name">{{$name}}</td><td>{{$value}}</td></tr>
+{{end}}
+</table>
+{{end}}
+
+<h2>Headers</h2>
+<table>
+{{range $name, $values := .Headers}}{{range $values}}<tr><td class="name">{{$name}}</td><td>{{.}}</td></tr>
+{{end}}{{end}}
+</table>
+
+<h2>Stack</h2>
+{{range .Frames}}
+<div class="frame">
+<div class="func">{{.Func}} <span class="file">{{.File}}:{{.Line}}</span></div>
+<pre>{{range .Lines}}<span{{if .Current}} class="current"{{end}}><i>{{.Number}}</i>{{.Code}}</span>{{end}}</pre>
+</div>
+{{end}}
+</body>
+</html>
+`
 )
 
+var panicTemplate = template.Must(template.New("panic").Parse(panicHtml))
+
 var (
 	dunno     = []byte("???")
 	dot       = []byte(".")
@@ -49,21 +108,180 @@ func stack(skip int) []byte {
 	return buf.Bytes()
 }
 
+type stackFrame struct {
+	Func   string       `json:"func"`
+	File   string       `json:"file"`
+	Line   int          `json:"line"`
+	Source string       `json:"source"`
+	Lines  []sourceLine `json:"-"`
+}
+
+type sourceLine struct {
+	Number  int
+	Code    string
+	Current bool
+}
+
+// 和 stack 一样, 但是保留文件位置和前后几行源码, 给错误页面用
+func stackFrames(skip int) []stackFrame {
+	frames := make([]stackFrame, 0, 10)
+
+	var lines [][]byte
+	var lastFile string
+	for i := skip; ; i++ {
+		pc, file, line, ok := runtime.Caller(i)
+
+		if !ok {
+			break
+		}
+
+		if file != lastFile {
+			data, err := ioutil.ReadFile(file)
+			if err != nil {
+				lines = nil
+			} else {
+				lines = bytes.Split(data, []byte{'\n'})
+			}
+			lastFile = file
+		}
+
+		frames = append(frames, stackFrame{
+			Func:   string(function(pc)),
+			File:   file,
+			Line:   line,
+			Source: string(source(lines, line)),
+			Lines:  sourceLines(lines, line, 5),
+		})
+	}
+	return frames
+}
+
+// 第 n 行前后各 radius 行, 不去掉缩进
+func sourceLines(lines [][]byte, n, radius int) []sourceLine {
+	if n < 1 || n > len(lines) {
+		return nil
+	}
+
+	start, end := n-radius, n+radius
+	if start < 1 {
+		start = 1
+	}
+	if end > len(lines) {
+		end = len(lines)
+	}
+
+	result := make([]sourceLine, 0, end-start+1)
+	for i := start; i <= end; i++ {
+		result = append(result, sourceLine{
+			Number:  i,
+			Code:    string(bytes.TrimRight(lines[i-1], "\r")),
+			Current: i == n,
+		})
+	}
+	return result
+}
+
+type panicPage struct {
+	Error   string       `json:"error"`
+	Method  string       `json:"method"`
+	URL     string       `json:"url"`
+	Pattern string       `json:"route,omitempty"`
+	Headers http.Header  `json:"headers"`
+	Params  Params       `json:"params,omitempty"`
+	Frames  []stackFrame `json:"stack"`
+}
+
+func newPanicPage(c *Context, err interface{}, frames []stackFrame) *panicPage {
+	return &panicPage{
+		Error:   fmt.Sprint(err),
+		Method:  c.Req.Method,
+		URL:     c.Req.URL.String(),
+		Pattern: c.pattern,
+		Headers: c.Req.Header,
+		Params:  c.params,
+		Frames:  frames,
+	}
+}
+
+// 客户端要 JSON 时输出 JSON, 否则输出 HTML
+func (p *panicPage) render(c *Context, rw http.ResponseWriter) []byte {
+	if strings.Contains(c.Req.Header.Get("Accept"), "application/json") {
+		body, err := json.MarshalIndent(p, "", "  ")
+		if err != nil {
+			return nil
+		}
+		rw.Header().Set("Content-Type", "application/json; charset=utf-8")
+		return body
+	}
+
+	buf := new(bytes.Buffer)
+	if err := panicTemplate.Execute(buf, p); err != nil {
+		return nil
+	}
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	return buf.Bytes()
+}
+
+type RecoveryOptions struct {
+	// 自定义 panic 之后的响应, stack 为格式化后的调用栈
+	PanicHandler func(*Context, interface{}, []byte)
+	// 上报错误, 在写响应之前调用
+	Reporter func(*Context, interface{}, []byte)
+	// 交给路由的 InternalServerError 处理
+	UseInternalServerError bool
+}
+
 func Recovery() Handler {
-	return func(c *Context, log *log.Logger) {
+	return RecoveryWithOptions()
+}
 
+func RecoveryWithOptions(options ...RecoveryOptions) Handler {
+	var opt RecoveryOptions
+	if len(options) > 0 {
+		opt = options[0]
+	}
+
+	return func(c *Context, log *log.Logger) {
 		defer func() {
-			if err := recover(); err != nil {
-				stack := stack(3)
-				log.Printf("PANIC: %s\n%s", err, stack)
+			err := recover()
+			if err == nil {
+				return
+			}
 
+			// 交给 http.Server 中断连接, 不需要打印
+			if err == http.ErrAbortHandler {
+				panic(err)
+			}
+
+			stack := stack(3)
+			log.Printf("PANIC: %s\n%s", err, stack)
+
+			if opt.Reporter != nil {
+				opt.Reporter(c, err, stack)
+			}
+
+			// 响应已经写了一部分, 状态码改不了了
+			// 中断连接, 让客户端知道响应不完整
+			if c.Written() {
+				panic(http.ErrAbortHandler)
+			}
+
+			switch {
+			case opt.PanicHandler != nil:
+				opt.PanicHandler(c, err, stack)
+			case opt.UseInternalServerError:
+				e, ok := err.(error)
+				if !ok {
+					e = fmt.Errorf("%v", err)
+				}
+				c.internalServerError(c, e)
+			default:
 				val := c.GetVal(inject.InterfaceOf((*http.ResponseWriter)(nil)))
 				res := val.Interface().(http.ResponseWriter)
 
 				var body []byte
-				if Env == DEV {
-					res.Header().Set("Content-Type", "text/html")
-					body = []byte(fmt.Sprintf(panicHtml, err, err, stack))
+				if safeEnv() == DEV {
+					body = newPanicPage(c, err, stackFrames(3)).render(c, res)
 				}
 
 				res.WriteHeader(http.StatusInternalServerError)
@@ -1,7 +1,23 @@
 package simple
 
 import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html/template"
 	"net/http"
+	"strings"
+)
+
+const (
+	_CONTENT_TYPE    = "Content-Type"
+	_CONTENT_BINARY  = "application/octet-stream"
+	_CONTENT_JSON    = "application/json"
+	_CONTENT_HTML    = "text/html"
+	_CONTENT_PLAIN   = "text/plain"
+	_CONTENT_XML     = "text/xml"
+	_DEFAULT_CHARSET = "UTF-8"
 )
 
 type Render interface {
@@ -9,6 +25,253 @@ type Render interface {
 	SetResponseWrite(http.ResponseWriter)
 
 	JSON(int, interface{})
+	JSONString(interface{}) (string, error)
+	XML(int, interface{})
+	HTML(int, string, interface{}, ...HTMLOptions)
+	HTMLSet(int, string, string, interface{}, ...HTMLOptions)
+	PlainText(int, []byte)
+	RawData(int, []byte)
+	Redirect(string, ...int)
+	Error(int, ...string)
+}
+
+type Delims struct {
+	Left  string
+	Right string
+}
+
+type RenderOptions struct {
+	// 模板目录, 相对路径基于 Root, 默认 templates
+	Directory string
+	// 模板文件后缀, 默认 .tmpl 和 .html
+	Extensions []string
+	// 默认布局模板名
+	Layout string
+	Funcs  []template.FuncMap
+	Delims Delims
+	// 默认 UTF-8
+	Charset    string
+	IndentJSON bool
+	IndentXML  bool
+	// 防 JSON 劫持, 例如 )]}',\n
+	PrefixJSON []byte
+	PrefixXML  []byte
+
+	JSONContentType      string
+	XMLContentType       string
+	HTMLContentType      string
+	PlainTextContentType string
+}
+
+func prepareRenderOptions(options []RenderOptions) RenderOptions {
+	var opt RenderOptions
+	if len(options) > 0 {
+		opt = options[0]
+	}
+
+	if len(opt.Directory) == 0 {
+		opt.Directory = "templates"
+	}
+	if len(opt.Extensions) == 0 {
+		opt.Extensions = []string{".tmpl", ".html"}
+	}
+	if len(opt.Charset) == 0 {
+		opt.Charset = _DEFAULT_CHARSET
+	}
+	if len(opt.JSONContentType) == 0 {
+		opt.JSONContentType = _CONTENT_JSON
+	}
+	if len(opt.XMLContentType) == 0 {
+		opt.XMLContentType = _CONTENT_XML
+	}
+	if len(opt.HTMLContentType) == 0 {
+		opt.HTMLContentType = _CONTENT_HTML
+	}
+	if len(opt.PlainTextContentType) == 0 {
+		opt.PlainTextContentType = _CONTENT_PLAIN
+	}
+	return opt
+}
+
+// 安装真正的 Render, 替换掉 Context 里的 DummyRender
+func Renderer(options ...RenderOptions) Handler {
+	return Renderers(prepareRenderOptions(options))
+}
+
+// 除了默认模板目录, 还可以指定多组模板, 格式为 name:dir
+// 使用 ctx.HTMLSet(200, "admin", "index") 渲染
+func Renderers(opt RenderOptions, tplSets ...string) Handler {
+	opt = prepareRenderOptions([]RenderOptions{opt})
+
+	sets := make(map[string]*templateSet, len(tplSets)+1)
+	set, err := newTemplateSet(DEFAULT_TPL_SET_NAME, opt.Directory, &opt)
+	if err != nil {
+		panic("fail to compile templates: " + err.Error())
+	}
+	sets[DEFAULT_TPL_SET_NAME] = set
+
+	for _, tplSet := range tplSets {
+		infos := strings.SplitN(tplSet, ":", 2)
+		if len(infos) != 2 || len(infos[0]) == 0 || len(infos[1]) == 0 {
+			panic("invalid template set: " + tplSet)
+		}
+
+		set, err := newTemplateSet(infos[0], infos[1], &opt)
+		if err != nil {
+			panic("fail to compile templates: " + err.Error())
+		}
+		sets[infos[0]] = set
+	}
+
+	return Provides(func(ctx *Context) {
+		for _, set := range sets {
+			set.bind(ctx.Router)
+		}
+
+		r := &TplRender{
+			ResponseWriter:  ctx.Resp,
+			req:             ctx.Req.Request,
+			opt:             &opt,
+			sets:            sets,
+			compiledCharset: "; charset=" + opt.Charset,
+		}
+		ctx.Render = r
+		ctx.MapTo(r, (*Render)(nil))
+	}, (*Render)(nil))
+}
+
+type TplRender struct {
+	http.ResponseWriter
+	req  *http.Request
+	opt  *RenderOptions
+	sets map[string]*templateSet
+
+	compiledCharset string
+}
+
+func (r *TplRender) SetResponseWrite(rw http.ResponseWriter) {
+	r.ResponseWriter = rw
+}
+
+func (r *TplRender) JSON(status int, v interface{}) {
+	var result []byte
+	var err error
+	if r.opt.IndentJSON {
+		result, err = json.MarshalIndent(v, "", "  ")
+	} else {
+		result, err = json.Marshal(v)
+	}
+	if err != nil {
+		http.Error(r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	r.Header().Set(_CONTENT_TYPE, r.opt.JSONContentType+r.compiledCharset)
+	r.WriteHeader(status)
+	if len(r.opt.PrefixJSON) > 0 {
+		r.Write(r.opt.PrefixJSON)
+	}
+	r.Write(result)
+}
+
+func (r *TplRender) JSONString(v interface{}) (string, error) {
+	var result []byte
+	var err error
+	if r.opt.IndentJSON {
+		result, err = json.MarshalIndent(v, "", "  ")
+	} else {
+		result, err = json.Marshal(v)
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(result), nil
+}
+
+func (r *TplRender) XML(status int, v interface{}) {
+	var result []byte
+	var err error
+	if r.opt.IndentXML {
+		result, err = xml.MarshalIndent(v, "", "  ")
+	} else {
+		result, err = xml.Marshal(v)
+	}
+	if err != nil {
+		http.Error(r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	r.Header().Set(_CONTENT_TYPE, r.opt.XMLContentType+r.compiledCharset)
+	r.WriteHeader(status)
+	if len(r.opt.PrefixXML) > 0 {
+		r.Write(r.opt.PrefixXML)
+	}
+	r.Write(result)
+}
+
+func (r *TplRender) HTML(status int, name string, data interface{}, htmlOpt ...HTMLOptions) {
+	r.HTMLSet(status, DEFAULT_TPL_SET_NAME, name, data, htmlOpt...)
+}
+
+// 先渲染到 buffer, 出错时还能返回 500
+func (r *TplRender) HTMLSet(status int, setName, tplName string, data interface{}, htmlOpt ...HTMLOptions) {
+	set, ok := r.sets[setName]
+	if !ok {
+		http.Error(r, "template set not found: "+setName, http.StatusInternalServerError)
+		return
+	}
+
+	layout := r.opt.Layout
+	if len(htmlOpt) > 0 {
+		layout = htmlOpt[0].Layout
+	}
+
+	buf := new(bytes.Buffer)
+	if err := set.execute(buf, tplName, data, layout); err != nil {
+		http.Error(r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	r.Header().Set(_CONTENT_TYPE, r.opt.HTMLContentType+r.compiledCharset)
+	r.WriteHeader(status)
+	buf.WriteTo(r)
+}
+
+func (r *TplRender) PlainText(status int, v []byte) {
+	r.Header().Set(_CONTENT_TYPE, r.opt.PlainTextContentType+r.compiledCharset)
+	r.WriteHeader(status)
+	r.Write(v)
+}
+
+func (r *TplRender) RawData(status int, v []byte) {
+	if r.Header().Get(_CONTENT_TYPE) == "" {
+		r.Header().Set(_CONTENT_TYPE, _CONTENT_BINARY)
+	}
+	r.WriteHeader(status)
+	r.Write(v)
+}
+
+// 默认 302
+func (r *TplRender) Redirect(location string, status ...int) {
+	code := http.StatusFound
+	if len(status) == 1 {
+		code = status[0]
+	}
+
+	http.Redirect(r, r.req, location, code)
+}
+
+// 没有 message 时使用状态码对应的文本
+func (r *TplRender) Error(status int, message ...string) {
+	text := http.StatusText(status)
+	if len(message) > 0 {
+		text = message[0]
+	}
+
+	r.Header().Set(_CONTENT_TYPE, r.opt.PlainTextContentType+r.compiledCharset)
+	r.Header().Set("X-Content-Type-Options", "nosniff")
+	r.WriteHeader(status)
+	fmt.Fprintln(r, text)
 }
 
 func renderNotRegistered() {
@@ -23,6 +286,39 @@ func (r *DummyRender) JSON(int, interface{}) {
 	renderNotRegistered()
 }
 
+func (r *DummyRender) JSONString(interface{}) (string, error) {
+	renderNotRegistered()
+	return "", nil
+}
+
+func (r *DummyRender) XML(int, interface{}) {
+	renderNotRegistered()
+}
+
+func (r *DummyRender) HTML(int, string, interface{}, ...HTMLOptions) {
+	renderNotRegistered()
+}
+
+func (r *DummyRender) HTMLSet(int, string, string, interface{}, ...HTMLOptions) {
+	renderNotRegistered()
+}
+
+func (r *DummyRender) PlainText(int, []byte) {
+	renderNotRegistered()
+}
+
+func (r *DummyRender) RawData(int, []byte) {
+	renderNotRegistered()
+}
+
+func (r *DummyRender) Redirect(string, ...int) {
+	renderNotRegistered()
+}
+
+func (r *DummyRender) Error(int, ...string) {
+	renderNotRegistered()
+}
+
 func (r *DummyRender) SetResponseWrite(http.ResponseWriter) {
 	renderNotRegistered()
 }
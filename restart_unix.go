@@ -0,0 +1,162 @@
+//go:build !windows
+
+package simple
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// 热重启时传给子进程的环境变量
+// 监听的 fd 从 3 开始, 后面一个是通知父进程已经就绪的管道
+const (
+	envListenFds = "SIMPLE_LISTEN_FDS"
+	envReadyFd   = "SIMPLE_READY_FD"
+)
+
+var restartSignals = []os.Signal{syscall.SIGHUP, syscall.SIGUSR2}
+
+var (
+	inheritOnce      sync.Once
+	inheritListeners []net.Listener
+	inheritErr       error
+)
+
+// 父进程传过来的第 i 个监听, 不是热重启启动的返回 nil
+func inheritedListener(i int) (net.Listener, error) {
+	inheritOnce.Do(func() {
+		n, _ := strconv.Atoi(os.Getenv(envListenFds))
+		os.Unsetenv(envListenFds)
+
+		for fd := listenFdsStart; fd < listenFdsStart+n; fd++ {
+			f := os.NewFile(uintptr(fd), "inherited_"+strconv.Itoa(fd))
+			ln, err := net.FileListener(f)
+			f.Close()
+			if err != nil {
+				inheritErr = fmt.Errorf("inherit listener: %v", err)
+				return
+			}
+			// 和自己创建的一样, 退出时删掉 socket 文件
+			if uln, ok := ln.(*net.UnixListener); ok {
+				uln.SetUnlinkOnClose(true)
+			}
+			inheritListeners = append(inheritListeners, ln)
+		}
+	})
+
+	if inheritErr != nil || i >= len(inheritListeners) {
+		return nil, inheritErr
+	}
+	return inheritListeners[i], nil
+}
+
+// 开始接受请求之后告诉父进程可以退出了
+func notifyReady() {
+	fd, err := strconv.Atoi(os.Getenv(envReadyFd))
+	if err != nil {
+		return
+	}
+	os.Unsetenv(envReadyFd)
+
+	f := os.NewFile(uintptr(fd), "ready")
+	f.Write([]byte{1})
+	f.Close()
+}
+
+type filer interface {
+	File() (*os.File, error)
+}
+
+// 用同样的参数启动一个新的进程, 把监听的 fd 传过去
+// 新进程就绪之后返回 nil, 这时可以关闭当前进程了
+func (m *Simple) restart(listeners []net.Listener) error {
+	var files []*os.File
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	for _, ln := range listeners {
+		fl, ok := ln.(filer)
+		if !ok {
+			return fmt.Errorf("listener %T can't be passed to child process", ln)
+		}
+
+		f, err := fl.File()
+		if err != nil {
+			return err
+		}
+		files = append(files, f)
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	files = append(files, w)
+
+	path, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	var env []string
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, envListenFds+"=") && !strings.HasPrefix(kv, envReadyFd+"=") {
+			env = append(env, kv)
+		}
+	}
+	env = append(env,
+		envListenFds+"="+strconv.Itoa(len(listeners)),
+		envReadyFd+"="+strconv.Itoa(listenFdsStart+len(listeners)))
+
+	cmd := exec.Command(path, os.Args[1:]...)
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = files
+	if err = cmd.Start(); err != nil {
+		return err
+	}
+	// 子进程退出时读到 EOF
+	w.Close()
+
+	ready := make(chan error, 1)
+	go func() {
+		b := make([]byte, 1)
+		_, err := r.Read(b)
+		ready <- err
+	}()
+
+	select {
+	case err = <-ready:
+	case <-time.After(m.serverOpt.RestartTimeout):
+		err = errors.New("timeout")
+	}
+	if err != nil {
+		cmd.Process.Kill()
+		go cmd.Wait()
+		return fmt.Errorf("child process(%d) not ready: %v", cmd.Process.Pid, err)
+	}
+
+	// socket 文件已经交给子进程了, 关闭时不能删掉
+	for _, ln := range listeners {
+		if uln, ok := ln.(*net.UnixListener); ok {
+			uln.SetUnlinkOnClose(false)
+		}
+	}
+
+	m.getLogger().Printf("child process(%d) is ready\n", cmd.Process.Pid)
+	return cmd.Process.Release()
+}
@@ -0,0 +1,163 @@
+//go:build !windows
+
+package simple
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// 父进程收到 SIGHUP 后重新执行测试程序, 子进程只运行这个测试
+// 子进程通过 envListenFds 拿到监听, 一直服务到 /quit
+func TestHotRestart(t *testing.T) {
+	if len(os.Getenv(envListenFds)) > 0 {
+		hotRestartChild()
+		return
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := "http://" + ln.Addr().String()
+
+	m := newWithLogger(ioutil.Discard)
+	m.SetServerOptions(ServerOptions{HotRestart: true, RestartTimeout: 10 * time.Second})
+	m.Get("/", func() string {
+		return strconv.Itoa(os.Getpid())
+	})
+
+	started := make(chan struct{})
+	m.OnStart(func() { close(started) })
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- m.Start(context.Background(), ln)
+	}()
+	select {
+	case <-started:
+	case err = <-errc:
+		t.Fatalf("Start: %v", err)
+	}
+
+	// 每个请求一个新的连接, 重启的过程中一直请求
+	client := &http.Client{
+		Transport: &http.Transport{DisableKeepAlives: true},
+		Timeout:   5 * time.Second,
+	}
+	get := func(path string) (string, error) {
+		resp, err := client.Get(addr + path)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		return string(body), err
+	}
+
+	var (
+		lock     sync.Mutex
+		failures []error
+		childPid string
+		served   = make(map[string]int)
+	)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+
+			pid, err := get("/")
+			lock.Lock()
+			if err != nil {
+				failures = append(failures, err)
+			} else {
+				served[pid]++
+				if pid != strconv.Itoa(os.Getpid()) {
+					childPid = pid
+				}
+			}
+			lock.Unlock()
+		}
+	}()
+
+	args := os.Args
+	os.Args = []string{os.Args[0], "-test.run=^TestHotRestart$"}
+	defer func() { os.Args = args }()
+
+	time.Sleep(50 * time.Millisecond)
+	syscall.Kill(os.Getpid(), syscall.SIGHUP)
+
+	select {
+	case err = <-errc:
+		if err != nil {
+			t.Errorf("parent Start returned %v", err)
+		}
+	case <-time.After(15 * time.Second):
+		t.Fatal("parent did not hand over the listener")
+	}
+
+	// 父进程已经关闭了自己的监听, 子进程继续接受连接
+	time.Sleep(50 * time.Millisecond)
+	close(stop)
+	<-done
+
+	// 子进程关闭监听之后连接会被拒绝
+	if _, err = get("/quit"); err != nil {
+		t.Errorf("fail to stop child: %v", err)
+	}
+	for deadline := time.Now().Add(10 * time.Second); ; time.Sleep(50 * time.Millisecond) {
+		if _, err = get("/"); err != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Error("child process is still serving after /quit")
+			break
+		}
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	if len(failures) > 0 {
+		t.Errorf("%d requests failed during restart, first: %v", len(failures), failures[0])
+	}
+	if len(childPid) == 0 {
+		t.Fatalf("no request served by the child process: %v", served)
+	}
+	if served[strconv.Itoa(os.Getpid())] == 0 {
+		t.Errorf("no request served by the parent process: %v", served)
+	}
+}
+
+func hotRestartChild() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	m := newWithLogger(ioutil.Discard)
+	m.Get("/", func() string {
+		return strconv.Itoa(os.Getpid())
+	})
+	m.Get("/quit", func() {
+		cancel()
+	})
+
+	code := 0
+	if err := m.Start(ctx); err != nil {
+		code = 1
+	}
+	// 不输出测试结果, 避免和父进程的混在一起
+	os.Exit(code)
+}
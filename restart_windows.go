@@ -0,0 +1,21 @@
+//go:build windows
+
+package simple
+
+import (
+	"errors"
+	"net"
+	"os"
+)
+
+var restartSignals []os.Signal
+
+func inheritedListener(i int) (net.Listener, error) {
+	return nil, nil
+}
+
+func notifyReady() {}
+
+func (m *Simple) restart(listeners []net.Listener) error {
+	return errors.New("hot restart is not supported on windows")
+}
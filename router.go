@@ -1,15 +1,25 @@
 package simple
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"reflect"
+	"sort"
 	"strings"
 	"sync"
+
+	"github.com/go-macaron/inject"
 )
 
 var _HTTP_METHODS = map[string]bool{
-	"GET":  true,
-	"POST": true,
+	"GET":     true,
+	"POST":    true,
+	"PUT":     true,
+	"DELETE":  true,
+	"PATCH":   true,
+	"OPTIONS": true,
+	"HEAD":    true,
 }
 
 type Route struct {
@@ -26,41 +36,182 @@ type Router struct {
 
 	groups              []group
 	notFound            http.HandlerFunc
+	methodNotAllowed    http.HandlerFunc
 	internalServerError func(*Context, error)
 
 	handlerWapper func(Handler) Handler
+
+	// 给 Simple.Validate 用
+	routes                  []routeInfo
+	notFoundInfo            []handlerInfo
+	methodNotAllowedInfo    []handlerInfo
+	internalServerErrorInfo []handlerInfo
+}
+
+// 给路由命名, 之后可以用 URLFor 反向生成 URL
+func (r *Route) Name(name string) {
+	if len(name) == 0 {
+		panic("route name cannot be empty")
+	} else if r.router.namedRoutes[name] != nil {
+		panic("route with given name already exists: " + name)
+	}
+
+	r.router.namedRoutes[name] = r.leaf
+}
+
+// pairs 为 参数名, 参数值 交替出现
+// r.URLFor("user_profile", "id", "1") => /user/1
+// 参数名可以带或者不带 ':', 通配符用 "*", 后缀路由用 ":path" 和 ":ext"
+func (r *Router) URLFor(name string, pairs ...string) (string, error) {
+	leaf, ok := r.namedRoutes[name]
+	if !ok {
+		return "", fmt.Errorf("route with given name does not exist: %s", name)
+	}
+
+	if len(pairs)%2 != 0 {
+		return "", fmt.Errorf("number of pairs does not match: %d", len(pairs))
+	}
+
+	params := make(Params, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		key := pairs[i]
+		if len(key) == 0 {
+			return "", fmt.Errorf("pair name cannot be empty: %d", i)
+		} else if key[0] != ':' && key != "*" {
+			key = ":" + key
+		}
+		params[key] = pairs[i+1]
+	}
+
+	path, err := leaf.URLPath(params)
+	if err != nil {
+		return "", err
+	}
+
+	if r.m != nil && r.m.hasURLPrefix {
+		path = r.m.urlPrefix + path
+	}
+	return path, nil
 }
 
 func (r *Router) NotFound(handlers ...Handler) {
+	r.notFoundInfo = newHandlerInfos(handlers)
 	handlers = validateAndWrapHandlers(handlers)
 
 	r.notFound = func(rw http.ResponseWriter, req *http.Request) {
 		c := r.m.createContext(rw, req)
 		c.handlers = make([]Handler, 0, len(r.m.handlers)+len(handlers))
-		c.handlers = append(c.handlers, r.m.handlers)
-		c.handlers = append(c.handlers, handlers)
+		c.handlers = append(c.handlers, r.m.handlers...)
+		c.handlers = append(c.handlers, handlers...)
 		c.run()
 	}
 }
 
+// 路径存在但方法不匹配时调用, 调用前已经设置好 Allow 头
+func (r *Router) MethodNotAllowed(handlers ...Handler) {
+	r.methodNotAllowedInfo = newHandlerInfos(handlers)
+	handlers = validateAndWrapHandlers(handlers)
+
+	r.methodNotAllowed = func(rw http.ResponseWriter, req *http.Request) {
+		c := r.m.createContext(rw, req)
+		c.handlers = make([]Handler, 0, len(r.m.handlers)+len(handlers))
+		c.handlers = append(c.handlers, r.m.handlers...)
+		c.handlers = append(c.handlers, handlers...)
+		c.run()
+	}
+}
+
+// 开启后 HEAD 请求没有对应路由时, 使用 GET 的路由处理
+// responseWriter 在 HEAD 时不会输出 body
+func (r *Router) SetAutoHead(v bool) {
+	r.autoHead = v
+}
+
 func (r *Router) InternalServerError(handlers ...Handler) {
+	r.internalServerErrorInfo = newHandlerInfos(handlers)
 	handlers = validateAndWrapHandlers(handlers)
 	r.internalServerError = func(c *Context, err error) {
 		c.index = 0
 		c.handlers = handlers
-		c.Map(err)
+		c.MapTo(err, (*error)(nil))
 		c.run()
 	}
 }
 
+// fn 里注册的路由都会加上 prefix, 并且先执行 group 的 handlers
+// 可以嵌套
+// r.Group("/api/v1", func() {
+// 	r.Get("/users", ...)
+// }, auth)
+func (r *Router) Group(prefix string, fn func(), handlers ...Handler) {
+	r.groups = append(r.groups, group{prefix, handlers})
+	fn()
+	r.groups = r.groups[:len(r.groups)-1]
+}
+
+// prefix 开头的请求去掉前缀之后交给 h 处理, 会先经过当前应用的中间件
+// h 是 *Simple 时可以使用当前请求里注入的值, 例如 session.Store, 同时保留自己的中间件
+// r.Mount("/api", api) 之后 /api/users 由 api 的 /users 处理
+func (r *Router) Mount(prefix string, h http.Handler) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if sub, ok := h.(*Simple); ok && sub == r.m {
+		panic("cannot mount app into itself")
+	}
+
+	handler := func(c *Context) {
+		req := c.Req.WithContext(context.WithValue(c.Req.Context(), mountContextKey{}, c))
+		u := *req.URL
+		u.Path = "/" + c.params["*"]
+		u.RawPath = ""
+		req.URL = &u
+		h.ServeHTTP(c.Resp, req)
+	}
+
+	if len(prefix) > 0 {
+		r.Any(prefix, handler)
+	}
+	r.Any(prefix+"/*", handler)
+}
+
+// 子应用的请求里保存父应用当前请求的 *Context
+type mountContextKey struct{}
+
+// 先在子应用里找, 找不到再到父应用当前请求的 Context 里找
+// 每个请求一个, 同一个子应用可以挂在多个父应用下面
+type mountInjector struct {
+	inject.Injector
+	parent inject.Injector
+}
+
+func (i *mountInjector) GetVal(t reflect.Type) reflect.Value {
+	if val := i.Injector.GetVal(t); val.IsValid() {
+		return val
+	}
+	return i.parent.GetVal(t)
+}
+
 func (r *Router) Handle(method string, pattern string, handlers []Handler) *Route {
+	// 外层 group 的前缀和 handler 在前
 	if len(r.groups) > 0 {
+		groupPattern := ""
+		h := make([]Handler, 0)
+		for _, g := range r.groups {
+			groupPattern += g.pattern
+			h = append(h, g.handlers...)
+		}
 
+		pattern = groupPattern + pattern
+		h = append(h, handlers...)
+		handlers = h
 	}
+
+	r.routes = append(r.routes, routeInfo{method, pattern, newHandlerInfos(handlers)})
+
 	handlers = validateAndWrapHandlers(handlers, r.handlerWapper)
 	return r.handle(method, pattern, func(resp http.ResponseWriter, req *http.Request, params Params) {
 		c := r.m.createContext(resp, req)
 		c.params = params
+		c.pattern = pattern
 		c.handlers = make([]Handler, 0, len(r.m.handlers)+len(handlers))
 		c.handlers = append(c.handlers, r.m.handlers...)
 		c.handlers = append(c.handlers, handlers...)
@@ -73,7 +224,6 @@ func (r *Router) handle(method string, pattern string, handle Handle) *Route {
 	var leaf *Leaf
 
 	if leaf = r.getLeaf(method, pattern); leaf != nil {
-		fmt.Println(pattern)
 		return &Route{r, leaf}
 	}
 
@@ -107,27 +257,93 @@ func (r *Router) handle(method string, pattern string, handle Handle) *Route {
 
 // client 入口
 func (r *Router) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
-	if t, ok := r.routers[req.Method]; ok {
-		leaf := r.getLeaf(req.Method, req.URL.Path)
-		if leaf != nil {
-			leaf.handle(rw, req, nil)
+	if r.serve(req.Method, rw, req) {
+		return
+	}
+
+	if req.Method == "HEAD" && r.autoHead && r.serve("GET", rw, req) {
+		return
+	}
+
+	// 路径存在, 只是方法不对
+	if allowed := r.allowedMethods(req); len(allowed) > 0 {
+		if req.Method == "OPTIONS" {
+			r.autoOptions(rw, req, allowed)
 			return
 		}
 
-		h, p, ok := t.Match(req.URL.EscapedPath())
+		rw.Header().Set("Allow", strings.Join(allowed, ", "))
+		r.methodNotAllowed(rw, req)
+		return
+	}
 
-		if ok {
-			if splat, ok := p["*0"]; ok {
-				p["*"] = splat
-			}
+	r.notFound(rw, req)
+}
 
-			// 调用 handle, 路由绑定
-			h(rw, req, p)
-			return
+// 没有注册 OPTIONS 路由时自动回复, 和其他请求一样先经过中间件
+func (r *Router) autoOptions(rw http.ResponseWriter, req *http.Request, allowed []string) {
+	c := r.m.createContext(rw, req)
+	c.handlers = make([]Handler, 0, len(r.m.handlers)+1)
+	c.handlers = append(c.handlers, r.m.handlers...)
+	c.handlers = append(c.handlers, ContextInvoker(func(c *Context) {
+		c.Resp.Header().Set("Allow", strings.Join(allowed, ", "))
+		c.Resp.WriteHeader(http.StatusOK)
+	}))
+	c.run()
+}
+
+func (r *Router) serve(method string, rw http.ResponseWriter, req *http.Request) bool {
+	t, ok := r.routers[method]
+	if !ok {
+		return false
+	}
+
+	leaf := r.getLeaf(method, req.URL.Path)
+	if leaf != nil {
+		leaf.handle(rw, req, nil)
+		return true
+	}
+
+	h, p, ok := t.Match(req.URL.EscapedPath())
+	if !ok {
+		return false
+	}
+
+	if splat, ok := p["*0"]; ok {
+		p["*"] = splat
+	}
+
+	// 调用 handle, 路由绑定
+	h(rw, req, p)
+	return true
+}
+
+// 根据 r.routers 计算当前路径允许的方法
+func (r *Router) allowedMethods(req *http.Request) []string {
+	allowed := make(map[string]bool)
+	for method, t := range r.routers {
+		if r.getLeaf(method, req.URL.Path) != nil {
+			allowed[method] = true
+		} else if _, _, ok := t.Match(req.URL.EscapedPath()); ok {
+			allowed[method] = true
 		}
 	}
 
-	r.NotFound(rw, req)
+	if len(allowed) == 0 {
+		return nil
+	}
+
+	if r.autoHead && allowed["GET"] {
+		allowed["HEAD"] = true
+	}
+	allowed["OPTIONS"] = true
+
+	methods := make([]string, 0, len(allowed))
+	for method := range allowed {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
 }
 
 func (r *Router) Get(pattern string, h ...Handler) (leaf *Route) {
@@ -139,6 +355,99 @@ func (r *Router) Post(pattern string, h ...Handler) (leaf *Route) {
 	return r.Handle("POST", pattern, h)
 }
 
+func (r *Router) Put(pattern string, h ...Handler) (leaf *Route) {
+	return r.Handle("PUT", pattern, h)
+}
+
+func (r *Router) Patch(pattern string, h ...Handler) (leaf *Route) {
+	return r.Handle("PATCH", pattern, h)
+}
+
+func (r *Router) Delete(pattern string, h ...Handler) (leaf *Route) {
+	return r.Handle("DELETE", pattern, h)
+}
+
+func (r *Router) Options(pattern string, h ...Handler) (leaf *Route) {
+	return r.Handle("OPTIONS", pattern, h)
+}
+
+func (r *Router) Head(pattern string, h ...Handler) (leaf *Route) {
+	return r.Handle("HEAD", pattern, h)
+}
+
+// 所有方法都注册同一组 handler
+func (r *Router) Any(pattern string, h ...Handler) (leaf *Route) {
+	return r.Handle("*", pattern, h)
+}
+
+// 同一路径上链式注册多个方法
+// r.Combo("/user").Get(...).Post(...).Delete(...)
+func (r *Router) Combo(pattern string, h ...Handler) *ComboRouter {
+	return &ComboRouter{r, pattern, h, make(map[string]bool), nil}
+}
+
+type ComboRouter struct {
+	router    *Router
+	pattern   string
+	handlers  []Handler
+	methods   map[string]bool
+	lastRoute *Route
+}
+
+func (cr *ComboRouter) checkMethod(name string) {
+	if cr.methods[name] {
+		panic("method '" + name + "' has already been registered")
+	}
+	cr.methods[name] = true
+}
+
+// 公共 handler 在前，每个方法自己的 handler 在后
+func (cr *ComboRouter) route(fn func(string, ...Handler) *Route, method string, h ...Handler) *ComboRouter {
+	cr.checkMethod(method)
+
+	handlers := make([]Handler, 0, len(cr.handlers)+len(h))
+	handlers = append(handlers, cr.handlers...)
+	handlers = append(handlers, h...)
+	cr.lastRoute = fn(cr.pattern, handlers...)
+	return cr
+}
+
+// 给最后注册的那个路由命名
+func (cr *ComboRouter) Name(name string) {
+	if cr.lastRoute == nil {
+		panic("no corresponding route to be named")
+	}
+	cr.lastRoute.Name(name)
+}
+
+func (cr *ComboRouter) Get(h ...Handler) *ComboRouter {
+	return cr.route(cr.router.Get, "GET", h...)
+}
+
+func (cr *ComboRouter) Post(h ...Handler) *ComboRouter {
+	return cr.route(cr.router.Post, "POST", h...)
+}
+
+func (cr *ComboRouter) Put(h ...Handler) *ComboRouter {
+	return cr.route(cr.router.Put, "PUT", h...)
+}
+
+func (cr *ComboRouter) Patch(h ...Handler) *ComboRouter {
+	return cr.route(cr.router.Patch, "PATCH", h...)
+}
+
+func (cr *ComboRouter) Delete(h ...Handler) *ComboRouter {
+	return cr.route(cr.router.Delete, "DELETE", h...)
+}
+
+func (cr *ComboRouter) Options(h ...Handler) *ComboRouter {
+	return cr.route(cr.router.Options, "OPTIONS", h...)
+}
+
+func (cr *ComboRouter) Head(h ...Handler) *ComboRouter {
+	return cr.route(cr.router.Head, "HEAD", h...)
+}
+
 type routeMap struct {
 	lock   sync.RWMutex
 	routes map[string]map[string]*Leaf
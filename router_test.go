@@ -0,0 +1,196 @@
+package simple
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+)
+
+func TestRouterMatchAndURLFor(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		url     string
+		params  Params
+	}{
+		{"static", "/about", "/about", Params{}},
+		{"placeholder", "/user/:name", "/user/unknwon", Params{":name": "unknwon"}},
+		{"regexp", "/user/:id([0-9]+)", "/user/123", Params{":id": "123"}},
+		{"regexp in segment", "/cms_:id([0-9]+).html", "/cms_12.html", Params{":id": "12"}},
+		{"two regexps in segment", "/date/:year([0-9]+)-:month([0-9]+)", "/date/2024-05", Params{":year": "2024", ":month": "05"}},
+		{"regexp subtree", "/user/:id([0-9]+)/posts", "/user/7/posts", Params{":id": "7"}},
+		{"two regexps in subtree", "/archive/:year([0-9]+)-:month([0-9]+)/list", "/archive/2024-05/list", Params{":year": "2024", ":month": "05"}},
+		{"int", "/item/:id:int", "/item/42", Params{":id": "42"}},
+		{"string", "/tag/:tag:string", "/tag/golang", Params{":tag": "golang"}},
+		{"int subtree", "/shop/:id:int/items", "/shop/3/items", Params{":id": "3"}},
+		{"optional with value", "/profile/?:id", "/profile/5", Params{":id": "5"}},
+		{"optional without value", "/account/?:id", "/account", Params{}},
+		{"glob", "/static/*", "/static/css/site.css", Params{"*": "css/site.css", "*0": "css/site.css"}},
+		{"glob after placeholder", "/repo/:owner/*", "/repo/unknwon/src/main.go", Params{":owner": "unknwon", "*": "src/main.go", "*1": "src/main.go"}},
+		{"path ext", "/files/*.*", "/files/readme.txt", Params{":path": "readme", ":ext": "txt"}},
+		{"path ext nested", "/docs/*.*", "/docs/guide/intro.md", Params{":path": "guide/intro", ":ext": "md"}},
+	}
+
+	m := newWithLogger(ioutil.Discard)
+	var (
+		got     Params
+		matched bool
+	)
+	for _, test := range tests {
+		m.Get(test.pattern, func(ctx *Context) {
+			got, matched = ctx.params, true
+		}).Name(test.name)
+	}
+
+	for _, test := range tests {
+		got, matched = nil, false
+		resp := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", test.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusOK || !matched {
+			t.Errorf("%s: GET %s returned %d", test.name, test.url, resp.Code)
+			continue
+		}
+		if len(got) != len(test.params) {
+			t.Errorf("%s: params = %v, want %v", test.name, got, test.params)
+		}
+		for k, v := range test.params {
+			if got[k] != v {
+				t.Errorf("%s: params[%q] = %q, want %q", test.name, k, got[k], v)
+			}
+		}
+
+		keys := make([]string, 0, len(test.params))
+		for k := range test.params {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		pairs := make([]string, 0, len(keys)*2)
+		for _, k := range keys {
+			pairs = append(pairs, k, test.params[k])
+		}
+
+		if u, err := m.URLFor(test.name, pairs...); err != nil {
+			t.Errorf("%s: URLFor: %v", test.name, err)
+		} else if u != test.url {
+			t.Errorf("%s: URLFor = %q, want %q", test.name, u, test.url)
+		}
+	}
+}
+
+func TestRouterURLForMissingParam(t *testing.T) {
+	m := newWithLogger(ioutil.Discard)
+	m.Get("/user/:id([0-9]+)", func() {}).Name("user")
+
+	if _, err := m.URLFor("user"); err == nil {
+		t.Error("URLFor without required param should fail")
+	}
+	if _, err := m.URLFor("nobody"); err == nil {
+		t.Error("URLFor with unknown name should fail")
+	}
+}
+
+func TestRouterAutoOptions(t *testing.T) {
+	m := newWithLogger(ioutil.Discard)
+	m.Use(func(ctx *Context) {
+		ctx.Resp.Header().Set("Access-Control-Allow-Origin", "*")
+	})
+	m.Get("/user/:id", func() {})
+	m.Post("/user/:id", func() {})
+
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("OPTIONS", "/user/1", nil)
+	m.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusOK {
+		t.Errorf("OPTIONS returned %d", resp.Code)
+	}
+	if allow := resp.Header().Get("Allow"); allow != "GET, OPTIONS, POST" {
+		t.Errorf("Allow = %q", allow)
+	}
+	if resp.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Error("middleware did not run for automatic OPTIONS reply")
+	}
+}
+
+func TestURLPrefix(t *testing.T) {
+	m := newWithLogger(ioutil.Discard)
+	m.SetURLPrefix("/blog/")
+	m.Get("/", func() string { return "index" })
+	m.Get("/ger", func() string { return "ger" })
+	m.Get("/posts/:id", func(ctx *Context) string { return ctx.Params("id") }).Name("post")
+
+	tests := []struct {
+		url  string
+		code int
+		body string
+	}{
+		{"/blog", http.StatusOK, "index"},
+		{"/blog/", http.StatusOK, "index"},
+		{"/blog/posts/1", http.StatusOK, "1"},
+		{"/blogger", http.StatusNotFound, ""},
+		{"/posts/1", http.StatusNotFound, ""},
+	}
+	for _, test := range tests {
+		resp := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", test.url, nil)
+		m.ServeHTTP(resp, req)
+
+		if resp.Code != test.code {
+			t.Errorf("GET %s returned %d, want %d", test.url, resp.Code, test.code)
+		} else if test.code == http.StatusOK && resp.Body.String() != test.body {
+			t.Errorf("GET %s = %q, want %q", test.url, resp.Body.String(), test.body)
+		}
+	}
+
+	if u, err := m.URLFor("post", "id", "1"); err != nil || u != "/blog/posts/1" {
+		t.Errorf("URLFor = %q, %v, want /blog/posts/1", u, err)
+	}
+}
+
+type mountUser struct {
+	name string
+}
+
+func TestMount(t *testing.T) {
+	sub := newWithLogger(ioutil.Discard)
+	sub.Map("sub value")
+	sub.Get("/whoami", func(ctx *Context, u *mountUser, s string) string {
+		return u.name + " " + s + " " + ctx.Req.URL.Path
+	})
+
+	newParent := func(name string) *Simple {
+		m := newWithLogger(ioutil.Discard)
+		m.Use(func(ctx *Context) {
+			ctx.Map(&mountUser{name + ":" + ctx.Query("user")})
+		})
+		m.Mount("/api", sub)
+		return m
+	}
+	a, b := newParent("a"), newParent("b")
+
+	tests := []struct {
+		m    *Simple
+		url  string
+		body string
+	}{
+		{a, "/api/whoami?user=1", "a:1 sub value /whoami"},
+		{b, "/api/whoami?user=2", "b:2 sub value /whoami"},
+		{a, "/api/whoami?user=3", "a:3 sub value /whoami"},
+	}
+	for _, test := range tests {
+		resp := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", test.url, nil)
+		test.m.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusOK || resp.Body.String() != test.body {
+			t.Errorf("GET %s = %d %q, want %q", test.url, resp.Code, resp.Body.String(), test.body)
+		}
+	}
+}
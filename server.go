@@ -0,0 +1,268 @@
+package simple
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+	"time"
+)
+
+type ServerOptions struct {
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+	// 收到退出信号后等待请求处理完的最长时间, 默认 30 秒
+	ShutdownTimeout time.Duration
+
+	// 监听 unix socket 时设置文件的权限和所有者, 为空时不修改
+	SocketMode  os.FileMode
+	SocketUser  string
+	SocketGroup string
+
+	// StartTLS 使用, 会复制一份, 证书文件加到复制的配置里
+	TLSConfig *tls.Config
+	// 不为空时 StartTLS 在这个地址上另外监听 http, 全部重定向到 https, 例如 ":80"
+	RedirectAddr string
+	// 大于 0 时每隔这么久检查一次证书文件, 有变化就重新加载, 不用重启
+	CertReloadInterval time.Duration
+	// 明文的 HTTP/2, 给内部服务用
+	H2C bool
+
+	// 收到 SIGHUP 或者 SIGUSR2 时启动新的进程接手监听, 新进程就绪后当前进程处理完请求退出
+	HotRestart bool
+	// 等待新进程就绪的最长时间, 默认 30 秒
+	RestartTimeout time.Duration
+}
+
+func prepareServerOptions(opt ServerOptions) ServerOptions {
+	if opt.ShutdownTimeout == 0 {
+		opt.ShutdownTimeout = 30 * time.Second
+	}
+	if opt.RestartTimeout == 0 {
+		opt.RestartTimeout = 30 * time.Second
+	}
+	return opt
+}
+
+// 需要在 Start 之前调用
+func (m *Simple) SetServerOptions(opt ServerOptions) {
+	m.serverOpt = prepareServerOptions(opt)
+}
+
+// 开始接受请求之前调用
+func (m *Simple) OnStart(fn func()) {
+	m.onStart = append(m.onStart, fn)
+}
+
+// 所有请求处理完之后调用
+func (m *Simple) OnShutdown(fn func()) {
+	m.onShutdown = append(m.onShutdown, fn)
+}
+
+func (m *Simple) getLogger() *log.Logger {
+	return m.GetVal(reflect.TypeOf(m.logger)).Interface().(*log.Logger)
+}
+
+func (m *Simple) newServer() *http.Server {
+	srv := &http.Server{
+		Handler:           m,
+		ReadTimeout:       m.serverOpt.ReadTimeout,
+		ReadHeaderTimeout: m.serverOpt.ReadHeaderTimeout,
+		WriteTimeout:      m.serverOpt.WriteTimeout,
+		IdleTimeout:       m.serverOpt.IdleTimeout,
+		MaxHeaderBytes:    m.serverOpt.MaxHeaderBytes,
+		ErrorLog:          m.getLogger(),
+	}
+
+	if m.serverOpt.H2C {
+		srv.Protocols = new(http.Protocols)
+		srv.Protocols.SetHTTP1(true)
+		srv.Protocols.SetHTTP2(true)
+		srv.Protocols.SetUnencryptedHTTP2(true)
+	}
+	return srv
+}
+
+// 参数和 Run 一样, 一直阻塞到服务停止
+// ctx 被取消或者收到 SIGINT, SIGTERM 时等待正在处理的请求结束再返回
+// 启动时 Validate 发现的问题只打印警告, 需要启动失败的先自己调用 Validate
+func (m *Simple) Start(ctx context.Context, args ...interface{}) error {
+	ln, err := m.listen(args...)
+	if err != nil {
+		return err
+	}
+	return m.serve(ctx, ln, m.newServer(), nil)
+}
+
+// extra 是 ln 以外需要在热重启时交给子进程的监听
+// redirect 是 StartTLS 的 http 跳转服务, 和 srv 一起 Shutdown
+func (m *Simple) serve(ctx context.Context, ln net.Listener, srv, redirect *http.Server, extra ...net.Listener) error {
+	m.serverLock.Lock()
+	if m.server != nil {
+		m.serverLock.Unlock()
+		ln.Close()
+		if redirect != nil {
+			redirect.Close()
+		}
+		return errors.New("simple: server already started")
+	}
+	m.server = srv
+	m.redirect = redirect
+	m.serverDone = make(chan struct{})
+	m.serverLock.Unlock()
+
+	// 只是警告, 有些类型可能是请求里动态 Map 的, 没有用 Provides 声明
+	if err := m.Validate(); err != nil {
+		m.getLogger().Printf("handler dependency problems:\n%v\n", err)
+	}
+
+	m.getLogger().Printf("listening on %s (%s)\n", ln.Addr(), safeEnv())
+	for _, fn := range m.onStart {
+		fn()
+	}
+
+	var conns *newConns
+	if m.serverOpt.HotRestart {
+		conns = &newConns{conns: make(map[net.Conn]bool)}
+		srv.ConnState = conns.track
+	}
+
+	serveLn := ln
+	if srv.TLSConfig != nil {
+		serveLn = tls.NewListener(ln, srv.TLSConfig)
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.Serve(serveLn)
+	}()
+	notifyReady()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sig)
+
+	restart := make(chan os.Signal, 1)
+	if m.serverOpt.HotRestart && len(restartSignals) > 0 {
+		signal.Notify(restart, restartSignals...)
+		defer signal.Stop(restart)
+	}
+
+	for {
+		select {
+		case err := <-errc:
+			if err == http.ErrServerClosed {
+				// 别的地方调用了 Shutdown, 等它结束
+				<-m.serverDone
+				return nil
+			}
+
+			if srv, redirect, done := m.takeServer(); srv != nil {
+				if redirect != nil {
+					redirect.Close()
+				}
+				close(done)
+			}
+			return err
+		case <-ctx.Done():
+		case s := <-sig:
+			m.getLogger().Printf("received %s, shutting down\n", s)
+		case s := <-restart:
+			m.getLogger().Printf("received %s, restarting\n", s)
+			if err := m.restart(append([]net.Listener{ln}, extra...)); err != nil {
+				m.getLogger().Printf("fail to restart: %v\n", err)
+				continue
+			}
+
+			// 子进程已经在 accept 了, 这边先停下来
+			// Shutdown 会直接关闭还没读到请求的连接, 等它们读到请求之后再 Shutdown
+			ln.Close()
+			<-errc
+			conns.wait(5 * time.Second)
+		}
+		break
+	}
+
+	sctx, cancel := context.WithTimeout(context.Background(), m.serverOpt.ShutdownTimeout)
+	defer cancel()
+	return m.Shutdown(sctx)
+}
+
+// 已经 accept 但是还没有读到请求的连接
+type newConns struct {
+	lock  sync.Mutex
+	conns map[net.Conn]bool
+}
+
+func (c *newConns) track(conn net.Conn, state http.ConnState) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if state == http.StateNew {
+		c.conns[conn] = true
+	} else {
+		delete(c.conns, conn)
+	}
+}
+
+// 最多等 timeout, 一直不发请求的连接就不管了
+func (c *newConns) wait(timeout time.Duration) {
+	for deadline := time.Now().Add(timeout); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		c.lock.Lock()
+		n := len(c.conns)
+		c.lock.Unlock()
+
+		if n == 0 {
+			return
+		}
+	}
+}
+
+func (m *Simple) takeServer() (srv, redirect *http.Server, done chan struct{}) {
+	m.serverLock.Lock()
+	defer m.serverLock.Unlock()
+
+	srv, redirect, done = m.server, m.redirect, m.serverDone
+	m.server, m.redirect = nil, nil
+	return srv, redirect, done
+}
+
+// 停止接受新的连接, 等待正在处理的请求结束, 然后调用 OnShutdown 注册的函数
+// ctx 到期时返回 ctx.Err(), 剩下的连接不再等待
+func (m *Simple) Shutdown(ctx context.Context) error {
+	srv, redirect, done := m.takeServer()
+	if srv == nil {
+		return nil
+	}
+	defer close(done)
+
+	// 两个一起停, 用同一个 ctx
+	var rerr error
+	var wg sync.WaitGroup
+	if redirect != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rerr = redirect.Shutdown(ctx)
+		}()
+	}
+	err := srv.Shutdown(ctx)
+	wg.Wait()
+	if err == nil {
+		err = rerr
+	}
+	for _, fn := range m.onShutdown {
+		fn()
+	}
+	return err
+}
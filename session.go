@@ -0,0 +1,154 @@
+package simple
+
+import (
+	"net/url"
+	"path/filepath"
+
+	"github.com/hehexianshi/simple/session"
+)
+
+type SessionOptions = session.Options
+
+const flashSessionKey = "_flash"
+
+// 和当前请求绑定的 session, Destroy 和 RegenerateID 需要改 cookie
+type sessionStore struct {
+	session.RawStore
+	ctx     *Context
+	manager *session.Manager
+	// Destroy 之后不再写回, 否则请求结束时又把删掉的 session 存回去
+	destroyed bool
+}
+
+func (s *sessionStore) Read(sid string) (session.RawStore, error) {
+	return s.manager.Read(sid)
+}
+
+func (s *sessionStore) Destroy() error {
+	if err := s.manager.Destroy(s.ctx.Resp, s.ctx.Req.Request); err != nil {
+		return err
+	}
+
+	s.RawStore.Flush()
+	s.destroyed = true
+	return nil
+}
+
+func (s *sessionStore) RegenerateID() error {
+	// 先写回, 新的 session 从存储里读出来时包含本次请求的修改
+	if !s.destroyed {
+		if err := s.RawStore.Release(); err != nil {
+			return err
+		}
+	}
+
+	raw, err := s.manager.RegenerateID(s.ctx.Resp, s.ctx.Req.Request)
+	if err != nil {
+		return err
+	}
+
+	s.RawStore = raw
+	s.destroyed = false
+	return nil
+}
+
+func (s *sessionStore) Count() int {
+	return s.manager.Count()
+}
+
+func (s *sessionStore) GC() {
+	s.manager.GC()
+}
+
+// 注入 session.Store 和 *Flash
+// 上一个请求设置的 flash 放在 ctx.Data["Flash"] 里给模板用
+func Sessioner(options ...SessionOptions) Handler {
+	opt := session.PrepareOptions(options)
+	if opt.Provider == "file" && len(opt.ProviderConfig) > 0 && !filepath.IsAbs(opt.ProviderConfig) {
+		opt.ProviderConfig = filepath.Join(Root, opt.ProviderConfig)
+	}
+
+	manager, err := session.NewManager(opt.Provider, opt)
+	if err != nil {
+		panic(err)
+	}
+	manager.StartGC()
+
+	return Provides(func(ctx *Context) {
+		raw, err := manager.Start(ctx.Resp, ctx.Req.Request)
+		if err != nil {
+			panic("session(start): " + err.Error())
+		}
+
+		sess := &sessionStore{RawStore: raw, ctx: ctx, manager: manager}
+		ctx.MapTo(sess, (*session.Store)(nil))
+
+		if val, ok := sess.Get(flashSessionKey).(string); ok {
+			sess.Delete(flashSessionKey)
+			if vals, err := url.ParseQuery(val); err == nil {
+				ctx.Data["Flash"] = &Flash{
+					ctx:        ctx,
+					Values:     vals,
+					ErrorMsg:   vals.Get("error"),
+					WarningMsg: vals.Get("warning"),
+					InfoMsg:    vals.Get("info"),
+					SuccessMsg: vals.Get("success"),
+				}
+			}
+		}
+
+		flash := &Flash{ctx: ctx, Values: make(url.Values)}
+		ctx.Map(flash)
+
+		ctx.Next()
+
+		if sess.destroyed {
+			return
+		}
+
+		if len(flash.Values) > 0 {
+			sess.Set(flashSessionKey, flash.Encode())
+		}
+
+		if err = sess.Release(); err != nil {
+			panic("session(release): " + err.Error())
+		}
+	}, (*session.Store)(nil), (*Flash)(nil))
+}
+
+// 下一个请求才显示的消息, 一般用在重定向之前
+// current 为 true 时在当前请求显示
+type Flash struct {
+	ctx *Context
+	url.Values
+	ErrorMsg, WarningMsg, InfoMsg, SuccessMsg string
+}
+
+func (f *Flash) set(name, msg string, current ...bool) {
+	if len(current) > 0 && current[0] {
+		f.ctx.Data["Flash"] = f
+		return
+	}
+
+	f.Set(name, msg)
+}
+
+func (f *Flash) Error(msg string, current ...bool) {
+	f.ErrorMsg = msg
+	f.set("error", msg, current...)
+}
+
+func (f *Flash) Warning(msg string, current ...bool) {
+	f.WarningMsg = msg
+	f.set("warning", msg, current...)
+}
+
+func (f *Flash) Info(msg string, current ...bool) {
+	f.InfoMsg = msg
+	f.set("info", msg, current...)
+}
+
+func (f *Flash) Success(msg string, current ...bool) {
+	f.SuccessMsg = msg
+	f.set("success", msg, current...)
+}
@@ -0,0 +1,207 @@
+package session
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// 每个 session 一个文件, 数据用 gob 编码
+// 文件的修改时间就是最后访问时间
+type FileStore struct {
+	p    *FileProvider
+	sid  string
+	lock sync.RWMutex
+	data map[interface{}]interface{}
+}
+
+func NewFileStore(p *FileProvider, sid string, kv map[interface{}]interface{}) *FileStore {
+	return &FileStore{
+		p:    p,
+		sid:  sid,
+		data: kv,
+	}
+}
+
+func (s *FileStore) Set(key, val interface{}) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.data[key] = val
+	return nil
+}
+
+func (s *FileStore) Get(key interface{}) interface{} {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.data[key]
+}
+
+func (s *FileStore) Delete(key interface{}) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.data, key)
+	return nil
+}
+
+func (s *FileStore) ID() string {
+	return s.sid
+}
+
+func (s *FileStore) Release() error {
+	s.lock.RLock()
+	data, err := EncodeGob(s.data)
+	s.lock.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	s.p.lock.Lock()
+	defer s.p.lock.Unlock()
+	return ioutil.WriteFile(s.p.filepath(s.sid), data, 0600)
+}
+
+func (s *FileStore) Flush() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.data = make(map[interface{}]interface{})
+	return nil
+}
+
+type FileProvider struct {
+	lock        sync.RWMutex
+	maxlifetime int64
+	rootPath    string
+}
+
+// config 为存放目录
+func (p *FileProvider) Init(maxlifetime int64, rootPath string) error {
+	if len(rootPath) == 0 {
+		rootPath = "data/sessions"
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.maxlifetime = maxlifetime
+	p.rootPath = rootPath
+	return os.MkdirAll(rootPath, 0700)
+}
+
+// 按前两个字符分目录, 避免一个目录里文件太多
+func (p *FileProvider) filepath(sid string) string {
+	return filepath.Join(p.rootPath, string(sid[0]), string(sid[1]), sid)
+}
+
+func (p *FileProvider) Read(sid string) (RawStore, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	filename := p.filepath(sid)
+	if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+		return nil, err
+	}
+
+	kv := make(map[interface{}]interface{})
+	data, err := ioutil.ReadFile(filename)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if len(data) > 0 {
+		if kv, err = DecodeGob(data); err != nil {
+			return nil, err
+		}
+	}
+
+	if os.IsNotExist(err) {
+		err = ioutil.WriteFile(filename, nil, 0600)
+	} else {
+		now := time.Now()
+		err = os.Chtimes(filename, now, now)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return NewFileStore(p, sid, kv), nil
+}
+
+func (p *FileProvider) Exist(sid string) bool {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	_, err := os.Stat(p.filepath(sid))
+	return err == nil
+}
+
+func (p *FileProvider) Destroy(sid string) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	err := os.Remove(p.filepath(sid))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (p *FileProvider) Regenerate(oldsid, sid string) (RawStore, error) {
+	if len(oldsid) > 0 && p.Exist(oldsid) {
+		p.lock.Lock()
+		newname := p.filepath(sid)
+		err := os.MkdirAll(filepath.Dir(newname), 0700)
+		if err == nil {
+			err = os.Rename(p.filepath(oldsid), newname)
+		}
+		p.lock.Unlock()
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return p.Read(sid)
+}
+
+func (p *FileProvider) walk(fn func(path string, fi os.FileInfo)) {
+	filepath.Walk(p.rootPath, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return nil
+		}
+		fn(path, fi)
+		return nil
+	})
+}
+
+func (p *FileProvider) Count() int {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	count := 0
+	p.walk(func(string, os.FileInfo) {
+		count++
+	})
+	return count
+}
+
+func (p *FileProvider) GC() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	deadline := time.Now().Add(-time.Duration(p.maxlifetime) * time.Second)
+	p.walk(func(path string, fi os.FileInfo) {
+		if fi.ModTime().Before(deadline) {
+			os.Remove(path)
+		}
+	})
+}
+
+func init() {
+	Register("file", func() Provider { return &FileProvider{} })
+}
@@ -0,0 +1,162 @@
+package session
+
+import (
+	"sync"
+	"time"
+)
+
+// 数据只在当前进程里, 重启就没了
+type MemStore struct {
+	sid        string
+	lock       sync.RWMutex
+	data       map[interface{}]interface{}
+	lastAccess time.Time
+}
+
+func NewMemStore(sid string) *MemStore {
+	return &MemStore{
+		sid:        sid,
+		data:       make(map[interface{}]interface{}),
+		lastAccess: time.Now(),
+	}
+}
+
+func (s *MemStore) Set(key, val interface{}) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.data[key] = val
+	return nil
+}
+
+func (s *MemStore) Get(key interface{}) interface{} {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.data[key]
+}
+
+func (s *MemStore) Delete(key interface{}) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.data, key)
+	return nil
+}
+
+func (s *MemStore) ID() string {
+	return s.sid
+}
+
+// 数据就在内存里, 不需要写回
+func (s *MemStore) Release() error {
+	return nil
+}
+
+func (s *MemStore) Flush() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.data = make(map[interface{}]interface{})
+	return nil
+}
+
+func (s *MemStore) touch() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.lastAccess = time.Now()
+}
+
+func (s *MemStore) expired(maxlifetime int64) bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.lastAccess.Add(time.Duration(maxlifetime) * time.Second).Before(time.Now())
+}
+
+type MemProvider struct {
+	lock        sync.RWMutex
+	maxlifetime int64
+	data        map[string]*MemStore
+}
+
+func (p *MemProvider) Init(maxlifetime int64, _ string) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.maxlifetime = maxlifetime
+	p.data = make(map[string]*MemStore)
+	return nil
+}
+
+func (p *MemProvider) Read(sid string) (RawStore, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if s, ok := p.data[sid]; ok {
+		s.touch()
+		return s, nil
+	}
+
+	s := NewMemStore(sid)
+	p.data[sid] = s
+	return s, nil
+}
+
+func (p *MemProvider) Exist(sid string) bool {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	_, ok := p.data[sid]
+	return ok
+}
+
+func (p *MemProvider) Destroy(sid string) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	delete(p.data, sid)
+	return nil
+}
+
+func (p *MemProvider) Regenerate(oldsid, sid string) (RawStore, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	s, ok := p.data[oldsid]
+	if !ok {
+		s = NewMemStore(sid)
+	} else {
+		delete(p.data, oldsid)
+		s.lock.Lock()
+		s.sid = sid
+		s.lastAccess = time.Now()
+		s.lock.Unlock()
+	}
+
+	p.data[sid] = s
+	return s, nil
+}
+
+func (p *MemProvider) Count() int {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return len(p.data)
+}
+
+func (p *MemProvider) GC() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for sid, s := range p.data {
+		if s.expired(p.maxlifetime) {
+			delete(p.data, sid)
+		}
+	}
+}
+
+func init() {
+	Register("memory", func() Provider { return &MemProvider{} })
+}
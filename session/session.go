@@ -0,0 +1,312 @@
+// 服务端 session, 存储由 Provider 实现
+// 内置 memory 和 file 两种, 也可以用 Register 注册别的
+package session
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// 一个 session 的数据
+type RawStore interface {
+	Set(key, value interface{}) error
+	Get(key interface{}) interface{}
+	Delete(key interface{}) error
+	ID() string
+	// 请求结束时调用, 把数据写回存储
+	Release() error
+	// 清空数据
+	Flush() error
+}
+
+// 注入到 handler 里的 session
+type Store interface {
+	RawStore
+	// 读取别的 session
+	Read(sid string) (RawStore, error)
+	// 删除当前 session 和 cookie
+	Destroy() error
+	// 换一个新的 session id, 数据保留, 登录成功后应该调用, 防止会话固定攻击
+	RegenerateID() error
+	Count() int
+	GC()
+}
+
+type Provider interface {
+	// maxlifetime 秒, config 由各个 Provider 自己解释
+	Init(maxlifetime int64, config string) error
+	Read(sid string) (RawStore, error)
+	Exist(sid string) bool
+	Destroy(sid string) error
+	// 把 oldsid 的数据移到 sid 下
+	Regenerate(oldsid, sid string) (RawStore, error)
+	Count() int
+	GC()
+}
+
+var (
+	providers    = make(map[string]func() Provider)
+	providerLock sync.RWMutex
+)
+
+// 每个 Manager 用 factory 创建自己的 Provider, 多个应用的 session 互不影响
+//
+//	Register("memory", func() Provider { return &MemProvider{} })
+func Register(name string, factory func() Provider) {
+	providerLock.Lock()
+	defer providerLock.Unlock()
+
+	if factory == nil {
+		panic("session: cannot register provider with nil factory")
+	}
+	if _, dup := providers[name]; dup {
+		panic(fmt.Errorf("session: cannot register provider '%s' twice", name))
+	}
+	providers[name] = factory
+}
+
+func newProvider(name string) (Provider, bool) {
+	providerLock.RLock()
+	defer providerLock.RUnlock()
+
+	factory, ok := providers[name]
+	if !ok {
+		return nil, false
+	}
+	return factory(), true
+}
+
+type Options struct {
+	// 默认 memory
+	Provider string
+	// file 为存放目录, 默认 data/sessions
+	ProviderConfig string
+	// 默认 SimpleSession
+	CookieName string
+	// 默认 /
+	CookiePath string
+	// GC 间隔, 秒, 默认 3600
+	Gclifetime int64
+	// session 最长空闲时间, 秒, 默认等于 Gclifetime
+	Maxlifetime int64
+	Secure      bool
+	// cookie 的 MaxAge, 0 为会话 cookie
+	CookieLifeTime int
+	Domain         string
+	// session id 的字节数, 默认 16
+	IDLength int
+}
+
+func PrepareOptions(options []Options) Options {
+	var opt Options
+	if len(options) > 0 {
+		opt = options[0]
+	}
+
+	if len(opt.Provider) == 0 {
+		opt.Provider = "memory"
+	}
+	if len(opt.CookieName) == 0 {
+		opt.CookieName = "SimpleSession"
+	}
+	if len(opt.CookiePath) == 0 {
+		opt.CookiePath = "/"
+	}
+	if opt.Gclifetime == 0 {
+		opt.Gclifetime = 3600
+	}
+	if opt.Maxlifetime == 0 {
+		opt.Maxlifetime = opt.Gclifetime
+	}
+	if opt.IDLength == 0 {
+		opt.IDLength = 16
+	}
+	return opt
+}
+
+type Manager struct {
+	provider Provider
+	opt      Options
+
+	gcOnce, stopOnce sync.Once
+	gcStop           chan struct{}
+}
+
+func NewManager(name string, opt Options) (*Manager, error) {
+	p, ok := newProvider(name)
+	if !ok {
+		return nil, fmt.Errorf("session: unknown provider '%s'(forgotten import?)", name)
+	}
+
+	m := &Manager{provider: p, opt: opt, gcStop: make(chan struct{})}
+	return m, p.Init(opt.Maxlifetime, opt.ProviderConfig)
+}
+
+func (m *Manager) sessionID() (string, error) {
+	b := make([]byte, m.opt.IDLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("fail to generate session id: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// 只接受自己生成的格式, file provider 会拿 sid 拼路径
+func (m *Manager) validSessionID(sid string) bool {
+	if len(sid) != m.opt.IDLength*2 {
+		return false
+	}
+	_, err := hex.DecodeString(sid)
+	return err == nil
+}
+
+func (m *Manager) cookieSessionID(req *http.Request) string {
+	cookie, err := req.Cookie(m.opt.CookieName)
+	if err != nil {
+		return ""
+	}
+
+	sid, _ := url.QueryUnescape(cookie.Value)
+	if !m.validSessionID(sid) {
+		return ""
+	}
+	return sid
+}
+
+func (m *Manager) setCookie(rw http.ResponseWriter, req *http.Request, sid string) {
+	cookie := &http.Cookie{
+		Name:     m.opt.CookieName,
+		Value:    sid,
+		Path:     m.opt.CookiePath,
+		HttpOnly: true,
+		Secure:   m.opt.Secure,
+		Domain:   m.opt.Domain,
+	}
+	if m.opt.CookieLifeTime > 0 {
+		cookie.MaxAge = m.opt.CookieLifeTime
+		cookie.Expires = time.Now().Add(time.Duration(m.opt.CookieLifeTime) * time.Second)
+	}
+	http.SetCookie(rw, cookie)
+
+	// 同一个请求后面再读 cookie 时能拿到新的 id
+	cookies := req.Cookies()
+	req.Header.Del("Cookie")
+	for _, c := range cookies {
+		if c.Name != m.opt.CookieName {
+			req.AddCookie(c)
+		}
+	}
+	req.AddCookie(&http.Cookie{Name: m.opt.CookieName, Value: sid})
+}
+
+// 有合法的 session cookie 就读取, 否则新建一个
+func (m *Manager) Start(rw http.ResponseWriter, req *http.Request) (RawStore, error) {
+	sid := m.cookieSessionID(req)
+	if len(sid) > 0 && m.provider.Exist(sid) {
+		return m.provider.Read(sid)
+	}
+
+	sid, err := m.sessionID()
+	if err != nil {
+		return nil, err
+	}
+
+	sess, err := m.provider.Read(sid)
+	if err != nil {
+		return nil, err
+	}
+
+	m.setCookie(rw, req, sid)
+	return sess, nil
+}
+
+func (m *Manager) Read(sid string) (RawStore, error) {
+	return m.provider.Read(sid)
+}
+
+func (m *Manager) Destroy(rw http.ResponseWriter, req *http.Request) error {
+	sid := m.cookieSessionID(req)
+	if len(sid) == 0 {
+		return nil
+	}
+
+	if err := m.provider.Destroy(sid); err != nil {
+		return err
+	}
+
+	http.SetCookie(rw, &http.Cookie{
+		Name:     m.opt.CookieName,
+		Path:     m.opt.CookiePath,
+		HttpOnly: true,
+		Domain:   m.opt.Domain,
+		MaxAge:   -1,
+		Expires:  time.Unix(0, 0),
+	})
+	return nil
+}
+
+func (m *Manager) RegenerateID(rw http.ResponseWriter, req *http.Request) (RawStore, error) {
+	sid, err := m.sessionID()
+	if err != nil {
+		return nil, err
+	}
+
+	sess, err := m.provider.Regenerate(m.cookieSessionID(req), sid)
+	if err != nil {
+		return nil, err
+	}
+
+	m.setCookie(rw, req, sid)
+	return sess, nil
+}
+
+func (m *Manager) Count() int {
+	return m.provider.Count()
+}
+
+func (m *Manager) GC() {
+	m.provider.GC()
+}
+
+// 每隔 Gclifetime 秒清理一次过期 session, 多次调用只启动一个
+// 不阻塞, 调用 StopGC 停止
+func (m *Manager) StartGC() {
+	m.gcOnce.Do(func() {
+		go func() {
+			m.GC()
+			ticker := time.NewTicker(time.Duration(m.opt.Gclifetime) * time.Second)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ticker.C:
+					m.GC()
+				case <-m.gcStop:
+					return
+				}
+			}
+		}()
+	})
+}
+
+func (m *Manager) StopGC() {
+	m.stopOnce.Do(func() { close(m.gcStop) })
+}
+
+// 自定义类型需要先 gob.Register
+func EncodeGob(obj map[interface{}]interface{}) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := gob.NewEncoder(buf).Encode(obj)
+	return buf.Bytes(), err
+}
+
+func DecodeGob(encoded []byte) (out map[interface{}]interface{}, err error) {
+	err = gob.NewDecoder(bytes.NewBuffer(encoded)).Decode(&out)
+	return out, err
+}
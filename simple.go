@@ -1,13 +1,14 @@
 package simple
 
 import (
-	"fmt"
+	"context"
 	"github.com/Unknwon/com"
 	"github.com/go-macaron/inject"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"sync"
@@ -24,21 +25,75 @@ type Simple struct {
 	*Router
 
 	logger *log.Logger
+
+	cookieSecret string
+
+	serverOpt  ServerOptions
+	serverLock sync.Mutex
+	server     *http.Server
+	redirect   *http.Server
+	serverDone chan struct{}
+	onStart    []func()
+	onShutdown []func()
+
+	middlewares []handlerInfo
 }
 
 type Handler interface{}
-type BeforeHandler func(rw http.ResponseWriter, req *http.Request) bool
+type BeforeHandler func(rw http.ResponseWriter, req *http.Request) *http.Request
 type handlerFuncInvoker func(http.ResponseWriter, *http.Request)
 
 // 如果是 fastInvoker 然后 inject 去调用Ivoker, 如果设置的话 就会重写Invoker
 // 核心调用在 inject
 func (invoke handlerFuncInvoker) Invoke(params []interface{}) ([]reflect.Value, error) {
-
+	invoke(params[0].(http.ResponseWriter), params[1].(*http.Request))
 	return nil, nil
 }
 
 type internalServerErrorInvoker func(rw http.ResponseWriter, err error)
 
+func (invoke internalServerErrorInvoker) Invoke(params []interface{}) ([]reflect.Value, error) {
+	invoke(params[0].(http.ResponseWriter), params[1].(error))
+	return nil, nil
+}
+
+var (
+	fastInvokers    = make(map[reflect.Type]func(Handler) inject.FastInvoker)
+	fastInvokerLock sync.RWMutex
+)
+
+// 给自定义签名的 handler 注册 fast invoker, 调用时不再使用反射
+// fn 只用来取函数类型, 可以是 nil 函数, wrap 把这个类型的 handler 转换成 invoker
+//
+//	type ConfigInvoker func(*Context, *Config)
+//	RegisterFastInvoker((func(*Context, *Config))(nil), func(h Handler) inject.FastInvoker {
+//		return ConfigInvoker(h.(func(*Context, *Config)))
+//	})
+func RegisterFastInvoker(fn Handler, wrap func(Handler) inject.FastInvoker) {
+	t := reflect.TypeOf(fn)
+	if t == nil || t.Kind() != reflect.Func {
+		panic("fast invoker must be registered with a function type")
+	} else if wrap == nil {
+		panic("fast invoker wrap function cannot be nil")
+	}
+
+	fastInvokerLock.Lock()
+	defer fastInvokerLock.Unlock()
+
+	if _, dup := fastInvokers[t]; dup {
+		panic("fast invoker already registered for type: " + t.String())
+	}
+	fastInvokers[t] = wrap
+}
+
+func getFastInvoker(t reflect.Type) (func(Handler) inject.FastInvoker, bool) {
+	fastInvokerLock.RLock()
+	defer fastInvokerLock.RUnlock()
+
+	wrap, ok := fastInvokers[t]
+	return wrap, ok
+}
+
 func newWithLogger(out io.Writer) *Simple {
 	m := &Simple{
 		Injector: inject.New(),
@@ -46,6 +101,7 @@ func newWithLogger(out io.Writer) *Simple {
 		Router:   NewRouter(),
 		logger:   log.New(out, "[Simple] ", 0),
 	}
+	m.serverOpt = prepareServerOptions(ServerOptions{})
 
 	m.Router.m = m
 	// inject 里的Map
@@ -54,10 +110,12 @@ func newWithLogger(out io.Writer) *Simple {
 
 	// 使用 http 的notFound
 	m.NotFound(http.NotFound)
+	m.MethodNotAllowed(func(c *Context) {
+		http.Error(c.Resp, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	})
 	m.InternalServerError(func(rw http.ResponseWriter, err error) {
 		http.Error(rw, err.Error(), 500)
 	})
-	fmt.Println(1)
 	return m
 }
 
@@ -85,6 +143,34 @@ var (
 	Root    string
 )
 
+func setENV(e string) {
+	envLock.Lock()
+	defer envLock.Unlock()
+
+	if len(e) > 0 {
+		Env = e
+	}
+}
+
+// 环境从 SIMPLE_ENV 读取, 兼容 MACARON_ENV
+// Root 为工作目录, 取不到时用程序所在目录
+func init() {
+	env := os.Getenv("SIMPLE_ENV")
+	if len(env) == 0 {
+		env = os.Getenv("MACARON_ENV")
+	}
+	setENV(env)
+
+	var err error
+	if Root, err = os.Getwd(); err != nil {
+		exe, err := os.Executable()
+		if err != nil {
+			panic("fail to get root path: " + err.Error())
+		}
+		Root = filepath.Dir(exe)
+	}
+}
+
 // 创建上下文
 func (m *Simple) createContext(rw http.ResponseWriter, req *http.Request) *Context {
 	c := &Context{
@@ -99,7 +185,11 @@ func (m *Simple) createContext(rw http.ResponseWriter, req *http.Request) *Conte
 		Data:     make(map[string]interface{}),
 	}
 
-	c.SetParent(m)
+	if parent, ok := req.Context().Value(mountContextKey{}).(*Context); ok {
+		c.SetParent(&mountInjector{m, parent})
+	} else {
+		c.SetParent(m)
+	}
 	c.Map(c)
 	c.MapTo(c.Resp, (*http.ResponseWriter)(nil))
 	c.Map(req)
@@ -107,10 +197,41 @@ func (m *Simple) createContext(rw http.ResponseWriter, req *http.Request) *Conte
 }
 
 func (m *Simple) Use(handlers Handler) {
+	if b, ok := handlers.(*beforeHandler); ok {
+		m.Before(b.before)
+		handlers = b.handler
+	}
+
+	m.middlewares = append(m.middlewares, newHandlerInfo(handlers))
 	handlers = validateAndWrapHandler(handlers)
 	m.handlers = append(m.handlers, handlers)
 }
 
+// 在路由匹配之前调用, 返回的请求交给后面的 before 和路由, 返回 nil 时不再继续处理
+// 需要修改请求时用 WithContext 之类的方法复制一份, 不要改传进来的 req
+func (m *Simple) Before(handler BeforeHandler) {
+	m.befores = append(m.befores, handler)
+}
+
+// 需要在路由匹配之前处理请求的中间件, 例如 I18n 去掉路径里的语言
+// Use 时 before 通过 Before 注册, 用在路由上时只执行 handler
+type beforeHandler struct {
+	handler Handler
+	before  BeforeHandler
+}
+
+// 应用部署在某个路径下面时使用, 例如反向代理把 /blog 转过来
+// 请求的路径会先去掉这个前缀, URLFor 生成的地址会带上这个前缀
+func (m *Simple) SetURLPrefix(prefix string) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if len(prefix) > 0 && prefix[0] != '/' {
+		prefix = "/" + prefix
+	}
+
+	m.hasURLPrefix = len(prefix) > 0
+	m.urlPrefix = prefix
+}
+
 func GetDefaultListenInfo() (string, int) {
 	host := os.Getenv("HOST")
 	if len(host) == 0 {
@@ -126,12 +247,21 @@ func GetDefaultListenInfo() (string, int) {
 }
 
 func (m *Simple) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	// /blog 只匹配 /blog 和 /blog/..., 不匹配 /blogger
 	if m.hasURLPrefix {
-		req.URL.Path = strings.TrimPrefix(req.URL.Path, m.urlPrefix)
+		if req.URL.Path == m.urlPrefix {
+			req.URL.Path = "/"
+		} else if strings.HasPrefix(req.URL.Path, m.urlPrefix+"/") {
+			req.URL.Path = req.URL.Path[len(m.urlPrefix):]
+		} else {
+			m.Router.notFound(rw, req)
+			return
+		}
+		req.URL.RawPath = ""
 	}
 
 	for _, h := range m.befores {
-		if h(rw, req) {
+		if req = h(rw, req); req == nil {
 			return
 		}
 	}
@@ -139,30 +269,11 @@ func (m *Simple) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	m.Router.ServeHTTP(rw, req)
 }
 
+// 出错时退出进程, 需要自己处理错误的用 Start
 func (m *Simple) Run(args ...interface{}) {
-	host, port := GetDefaultListenInfo()
-
-	if len(args) == 1 {
-		switch arg := args[0].(type) {
-		case string:
-			host = arg
-		case int:
-			port = arg
-		}
-	} else if len(args) >= 2 {
-		if arg, ok := args[0].(string); ok {
-			host = arg
-		}
-
-		if arg, ok := args[0].(int); ok {
-			port = arg
-		}
+	if err := m.Start(context.Background(), args...); err != nil {
+		m.getLogger().Fatalln(err)
 	}
-
-	addr := host + ":" + com.ToStr(port)
-	logger := m.GetVal(reflect.TypeOf(m.logger)).Interface().(*log.Logger)
-	logger.Printf("listening on %s  (%s) \n", addr, safeEnv())
-	logger.Fatalln(http.ListenAndServe(addr, m))
 }
 
 func safeEnv() string {
@@ -178,6 +289,13 @@ func safeEnv() string {
 // 如果handler 不是 isFastInvoker
 // 转化成相应的invoker
 func validateAndWrapHandler(h Handler) Handler {
+	if b, ok := h.(*beforeHandler); ok {
+		h = b.handler
+	}
+	if p, ok := h.(*providesHandler); ok {
+		h = p.handler
+	}
+
 	if reflect.TypeOf(h).Kind() != reflect.Func {
 		// 此处 如此处理 欠妥当
 		panic("func must bu callable function")
@@ -194,6 +312,10 @@ func validateAndWrapHandler(h Handler) Handler {
 		case func(http.ResponseWriter, error):
 			return internalServerErrorInvoker(v)
 		}
+
+		if wrap, ok := getFastInvoker(reflect.TypeOf(h)); ok {
+			return wrap(h)
+		}
 	}
 
 	return h
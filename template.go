@@ -0,0 +1,249 @@
+package simple
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DEFAULT_TPL_SET_NAME = "DEFAULT"
+
+type HTMLOptions struct {
+	// 覆盖 RenderOptions.Layout, 为空表示不使用布局
+	Layout string
+}
+
+// 一个目录对应一组模板
+// 模板名为相对目录的路径, 不带后缀, 例如 user/profile
+// 模板之间可以用 {{template "partials/header" .}} 互相引用
+// 布局模板里用 {{yield}} 输出内容, {{current}} 为当前模板名
+type templateSet struct {
+	name string
+	dir  string
+	opt  *RenderOptions
+
+	lock sync.RWMutex
+	// base 从不执行, 渲染布局时 Clone 一份再绑定 yield
+	// html/template 执行过之后就不能 Clone 了
+	base *template.Template
+	tpl  *template.Template
+	// 用来判断目录是否变化
+	modTime time.Time
+	files   int
+
+	routerOnce sync.Once
+	router     *Router
+}
+
+func newTemplateSet(name, dir string, opt *RenderOptions) (*templateSet, error) {
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(Root, dir)
+	}
+
+	s := &templateSet{
+		name: name,
+		dir:  dir,
+		opt:  opt,
+	}
+	return s, s.compile()
+}
+
+func (s *templateSet) funcs() template.FuncMap {
+	return template.FuncMap{
+		"yield": func() (template.HTML, error) {
+			return "", fmt.Errorf("yield called with no layout defined")
+		},
+		"current": func() (string, error) {
+			return "", nil
+		},
+		"URLFor": func(name string, pairs ...string) (string, error) {
+			if s.router == nil {
+				return "", fmt.Errorf("router hasn't been bound to template set: %s", s.name)
+			}
+			return s.router.URLFor(name, pairs...)
+		},
+	}
+}
+
+func (s *templateSet) compile() error {
+	base := template.New(s.dir)
+	base.Delims(s.opt.Delims.Left, s.opt.Delims.Right)
+	base.Funcs(s.funcs())
+	for _, funcs := range s.opt.Funcs {
+		base.Funcs(funcs)
+	}
+
+	modTime, files, err := s.stat()
+	if err != nil {
+		return err
+	}
+
+	if files > 0 {
+		err = s.walk(func(path, name string) error {
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+
+			if _, err = base.New(name).Parse(string(data)); err != nil {
+				return fmt.Errorf("%s: %v", path, err)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	tpl, err := base.Clone()
+	if err != nil {
+		return err
+	}
+
+	s.base = base
+	s.tpl = tpl
+	s.modTime = modTime
+	s.files = files
+	return nil
+}
+
+// 遍历目录下所有模板文件
+func (s *templateSet) walk(fn func(path, name string) error) error {
+	return filepath.Walk(s.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		ext := filepath.Ext(path)
+		if !isTemplateExt(ext, s.opt.Extensions) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.dir, path)
+		if err != nil {
+			return err
+		}
+		return fn(path, filepath.ToSlash(strings.TrimSuffix(rel, ext)))
+	})
+}
+
+// 目录不存在时没有模板
+func (s *templateSet) stat() (modTime time.Time, files int, err error) {
+	if _, err = os.Stat(s.dir); os.IsNotExist(err) {
+		return modTime, 0, nil
+	}
+
+	err = s.walk(func(path, name string) error {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+
+		files++
+		if fi.ModTime().After(modTime) {
+			modTime = fi.ModTime()
+		}
+		return nil
+	})
+	return modTime, files, err
+}
+
+// 开发模式下文件有变化就重新编译, 其它模式一直用缓存
+func (s *templateSet) reload() error {
+	if safeEnv() != DEV {
+		return nil
+	}
+
+	modTime, files, err := s.stat()
+	if err != nil {
+		return err
+	}
+
+	s.lock.RLock()
+	changed := !modTime.Equal(s.modTime) || files != s.files
+	s.lock.RUnlock()
+	if !changed {
+		return nil
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.compile()
+}
+
+func (s *templateSet) bind(r *Router) {
+	s.routerOnce.Do(func() {
+		s.router = r
+	})
+}
+
+func (s *templateSet) execute(w io.Writer, name string, data interface{}, layout string) error {
+	if err := s.reload(); err != nil {
+		return err
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if len(layout) == 0 {
+		return s.tpl.ExecuteTemplate(w, name, data)
+	}
+
+	content := new(bytes.Buffer)
+	if err := s.tpl.ExecuteTemplate(content, name, data); err != nil {
+		return err
+	}
+
+	t, err := s.base.Clone()
+	if err != nil {
+		return err
+	}
+	t.Funcs(template.FuncMap{
+		"yield": func() (template.HTML, error) {
+			return template.HTML(content.String()), nil
+		},
+		"current": func() (string, error) {
+			return name, nil
+		},
+	})
+	return t.ExecuteTemplate(w, layout, data)
+}
+
+func isTemplateExt(ext string, extensions []string) bool {
+	for _, e := range extensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// 没有传 data 时使用 ctx.Data
+func (ctx *Context) renderHTML(status int, setName, tplName string, data ...interface{}) {
+	switch len(data) {
+	case 0:
+		ctx.Render.HTMLSet(status, setName, tplName, ctx.Data)
+	case 1:
+		ctx.Render.HTMLSet(status, setName, tplName, data[0])
+	default:
+		ctx.Render.HTMLSet(status, setName, tplName, data[0], data[1].(HTMLOptions))
+	}
+}
+
+func (ctx *Context) HTML(status int, name string, data ...interface{}) {
+	ctx.renderHTML(status, DEFAULT_TPL_SET_NAME, name, data...)
+}
+
+func (ctx *Context) HTMLSet(status int, setName, tplName string, data ...interface{}) {
+	ctx.renderHTML(status, setName, tplName, data...)
+}
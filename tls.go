@@ -0,0 +1,197 @@
+package simple
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 出错时退出进程, 参数和 Run 一样
+func (m *Simple) RunTLS(certFile, keyFile string, args ...interface{}) {
+	if err := m.StartTLS(context.Background(), certFile, keyFile, args...); err != nil {
+		m.getLogger().Fatalln(err)
+	}
+}
+
+// 和 Start 一样, 只是使用 https, 同时支持 HTTP/2
+// certFile 和 keyFile 为空时使用 ServerOptions.TLSConfig 里的证书
+func (m *Simple) StartTLS(ctx context.Context, certFile, keyFile string, args ...interface{}) error {
+	config, err := m.tlsConfig(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+
+	ln, err := m.listen(args...)
+	if err != nil {
+		return err
+	}
+
+	var (
+		redirect *http.Server
+		extra    []net.Listener
+	)
+	if len(m.serverOpt.RedirectAddr) > 0 {
+		// 不是 tcp 的时候一般前面还有代理, 当作 443
+		port := 443
+		if addr, ok := ln.Addr().(*net.TCPAddr); ok {
+			port = addr.Port
+		}
+		redirect = &http.Server{
+			Addr:              m.serverOpt.RedirectAddr,
+			Handler:           httpsRedirectHandler(port),
+			ReadHeaderTimeout: m.serverOpt.ReadHeaderTimeout,
+			ErrorLog:          m.getLogger(),
+		}
+
+		rln, err := inheritedListener(1)
+		if rln == nil && err == nil {
+			rln, err = net.Listen("tcp", redirect.Addr)
+		}
+		if err != nil {
+			ln.Close()
+			return err
+		}
+		extra = append(extra, rln)
+
+		go func() {
+			if err := redirect.Serve(rln); err != http.ErrServerClosed {
+				m.getLogger().Printf("https redirect: %v\n", err)
+			}
+		}()
+	}
+
+	srv := m.newServer()
+	srv.TLSConfig = config
+	return m.serve(ctx, ln, srv, redirect, extra...)
+}
+
+func (m *Simple) tlsConfig(certFile, keyFile string) (*tls.Config, error) {
+	var config *tls.Config
+	if m.serverOpt.TLSConfig != nil {
+		config = m.serverOpt.TLSConfig.Clone()
+	} else {
+		config = &tls.Config{}
+	}
+
+	if len(config.NextProtos) == 0 {
+		config.NextProtos = []string{"h2", "http/1.1"}
+	}
+
+	if len(certFile) == 0 && len(keyFile) == 0 {
+		if len(config.Certificates) == 0 && config.GetCertificate == nil {
+			return nil, errors.New("simple: no tls certificate given")
+		}
+		return config, nil
+	}
+
+	if m.serverOpt.CertReloadInterval > 0 {
+		r, err := newCertReloader(certFile, keyFile, m.serverOpt.CertReloadInterval)
+		if err != nil {
+			return nil, err
+		}
+		r.logger = m.getLogger()
+		config.GetCertificate = r.GetCertificate
+		return config, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	config.Certificates = append(config.Certificates, cert)
+	return config, nil
+}
+
+// 端口是 443 时不带端口
+func httpsRedirectHandler(port int) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		host, _, err := net.SplitHostPort(req.Host)
+		if err != nil {
+			host = req.Host
+		}
+		if port != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(port))
+		}
+
+		http.Redirect(rw, req, "https://"+host+req.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
+
+// 握手时检查证书文件的修改时间, 最多 interval 检查一次
+// 加载失败时继续使用旧的证书
+type certReloader struct {
+	certFile, keyFile string
+	interval          time.Duration
+	logger            *log.Logger
+
+	lock    sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+	checked time.Time
+}
+
+func newCertReloader(certFile, keyFile string, interval time.Duration) (*certReloader, error) {
+	r := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		interval: interval,
+	}
+	return r, r.load()
+}
+
+func (r *certReloader) fileModTime() (time.Time, error) {
+	var latest time.Time
+	for _, name := range []string{r.certFile, r.keyFile} {
+		fi, err := os.Stat(name)
+		if err != nil {
+			return latest, err
+		}
+		if fi.ModTime().After(latest) {
+			latest = fi.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+func (r *certReloader) load() error {
+	modTime, err := r.fileModTime()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+
+	r.cert = &cert
+	r.modTime = modTime
+	r.checked = time.Now()
+	return nil
+}
+
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if time.Since(r.checked) < r.interval {
+		return r.cert, nil
+	}
+	r.checked = time.Now()
+
+	modTime, err := r.fileModTime()
+	if err == nil && modTime.After(r.modTime) {
+		err = r.load()
+	}
+	if err != nil && r.logger != nil {
+		r.logger.Printf("fail to reload certificate: %v\n", err)
+	}
+	return r.cert, nil
+}
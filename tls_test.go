@@ -0,0 +1,243 @@
+package simple
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// 生成 127.0.0.1 的自签名证书, 写到 dir 下的 cert.pem 和 key.pem
+func writeTestCert(t *testing.T, dir, commonName string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	if err = ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func freeAddr(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().String()
+}
+
+func TestStartTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "simple-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile := writeTestCert(t, dir, "simple")
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	redirectAddr := freeAddr(t)
+
+	m := newWithLogger(ioutil.Discard)
+	m.SetServerOptions(ServerOptions{RedirectAddr: redirectAddr})
+	m.Get("/", func(ctx *Context) {
+		ctx.Resp.Write([]byte(ctx.Req.Proto))
+	})
+
+	started := make(chan struct{})
+	m.OnStart(func() { close(started) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		errc <- m.StartTLS(ctx, certFile, keyFile, ln)
+	}()
+
+	select {
+	case <-started:
+	case err = <-errc:
+		t.Fatalf("StartTLS: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not start")
+	}
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+			ForceAttemptHTTP2: true,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resp, err := client.Get("https://" + ln.Addr().String() + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "HTTP/2.0" {
+		t.Errorf("https response = %d %q, want 200 over HTTP/2.0", resp.StatusCode, body)
+	}
+
+	resp, err = client.Get("http://" + redirectAddr + "/users?page=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	want := "https://127.0.0.1:" + strconv.Itoa(port) + "/users?page=2"
+	if resp.StatusCode != http.StatusMovedPermanently || resp.Header.Get("Location") != want {
+		t.Errorf("redirect = %d %q, want 301 %q", resp.StatusCode, resp.Header.Get("Location"), want)
+	}
+
+	cancel()
+	select {
+	case err = <-errc:
+		if err != nil {
+			t.Errorf("StartTLS returned %v after cancel", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not stop")
+	}
+	if _, err = client.Get("http://" + redirectAddr + "/"); err == nil {
+		t.Error("redirect server is still running after StartTLS returned")
+	}
+}
+
+func TestStartTLSWithoutCertificate(t *testing.T) {
+	m := newWithLogger(ioutil.Discard)
+	if err := m.StartTLS(context.Background(), "", ""); err == nil {
+		t.Error("StartTLS without certificate should fail")
+	}
+}
+
+func TestHTTPSRedirectHandler(t *testing.T) {
+	tests := []struct {
+		port     int
+		host     string
+		url      string
+		location string
+	}{
+		{443, "example.com", "/", "https://example.com/"},
+		{443, "example.com:80", "/a/b?c=d", "https://example.com/a/b?c=d"},
+		{8443, "example.com:8080", "/a", "https://example.com:8443/a"},
+		{8443, "[::1]:8080", "/", "https://[::1]:8443/"},
+	}
+
+	for _, test := range tests {
+		resp := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", test.url, nil)
+		req.Host = test.host
+		httpsRedirectHandler(test.port).ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusMovedPermanently {
+			t.Errorf("%s%s: code = %d", test.host, test.url, resp.Code)
+		}
+		if loc := resp.Header().Get("Location"); loc != test.location {
+			t.Errorf("%s%s: Location = %q, want %q", test.host, test.url, loc, test.location)
+		}
+	}
+}
+
+func TestCertReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "simple-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile := writeTestCert(t, dir, "first")
+
+	r, err := newCertReloader(certFile, keyFile, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.logger = newWithLogger(ioutil.Discard).getLogger()
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{GetCertificate: r.GetCertificate})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: http.NotFoundHandler()}
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	peerName := func() string {
+		conn, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		return conn.ConnectionState().PeerCertificates[0].Subject.CommonName
+	}
+
+	if name := peerName(); name != "first" {
+		t.Fatalf("certificate = %q, want first", name)
+	}
+
+	// 修改时间往后调, 不依赖文件系统的时间精度
+	writeTestCert(t, dir, "second")
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, later, later)
+	os.Chtimes(keyFile, later, later)
+	time.Sleep(10 * time.Millisecond)
+
+	if name := peerName(); name != "second" {
+		t.Fatalf("certificate = %q after reload, want second", name)
+	}
+
+	// 加载失败时继续使用旧的证书
+	if err = ioutil.WriteFile(certFile, []byte("broken"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	later = later.Add(time.Minute)
+	os.Chtimes(certFile, later, later)
+	time.Sleep(10 * time.Millisecond)
+
+	if name := peerName(); name != "second" {
+		t.Fatalf("certificate = %q after broken reload, want second", name)
+	}
+}
@@ -3,6 +3,7 @@ package simple
 import (
 	"fmt"
 	"github.com/Unknwon/com"
+	"net/url"
 	"regexp"
 	"strings"
 )
@@ -49,7 +50,9 @@ func getRawPattern(rawPattern string) string {
 
 		closeIndex := strings.Index(rawPattern, ")")
 		if closeIndex > -1 {
-			rawPattern = rawPattern[:startIndex] + rawPattern[closeIndex:]
+			rawPattern = rawPattern[:startIndex] + rawPattern[closeIndex+1:]
+		} else {
+			break
 		}
 	}
 
@@ -188,7 +191,6 @@ func NewLeaf(parent *Tree, pattern string, handle Handle) *Leaf {
 		optional = true
 	}
 
-	fmt.Println(parent, typ, pattern, rawPattern, wildcards, reg, optional, handle)
 	return &Leaf{parent, typ, pattern, rawPattern, wildcards, reg, optional, handle}
 }
 
@@ -246,7 +248,6 @@ func (t *Tree) Match(url string) (Handle, Params, bool) {
 
 	params := make(Params)
 	handle, ok := t.matchNextSegment(0, url, params)
-	fmt.Println(handle, params, ok)
 	return handle, params, ok
 }
 
@@ -323,7 +324,7 @@ func (t *Tree) matchSubtree(globLevel int, segment, url string, params Params) (
 				break
 			}
 
-			for j := 0; j < len(t.subtrees[i].wildcards); i++ {
+			for j := 0; j < len(t.subtrees[i].wildcards); j++ {
 				params[t.subtrees[i].wildcards[j]] = results[j+1]
 			}
 
@@ -383,3 +384,101 @@ type Leaf struct {
 
 	handle Handle
 }
+
+// 根据参数反向生成 URL
+// 从叶子节点一直往上找到根节点，每一段用 rawPattern 和 wildcards 还原
+func (l *Leaf) URLPath(params Params) (string, error) {
+	segment, err := buildSegment(l.typ, l.pattern, l.rawPattern, l.wildcards, l.reg, params)
+	if err != nil {
+		// /user/?:id 没有传 id 时生成 /user
+		if !l.optional {
+			return "", err
+		}
+		segment = ""
+	}
+
+	segments := []string{segment}
+	for t := l.parent; t != nil && t.parent != nil; t = t.parent {
+		segment, err = buildSegment(t.typ, t.pattern, t.rawPattern, t.wildcards, t.reg, params)
+		if err != nil {
+			return "", err
+		}
+		segments = append([]string{segment}, segments...)
+	}
+
+	urlPath := "/" + strings.Join(segments, "/")
+	if len(urlPath) > 1 {
+		urlPath = strings.TrimSuffix(urlPath, "/")
+	}
+	return urlPath, nil
+}
+
+func buildSegment(typ patternType, pattern, rawPattern string, wildcards []string, reg *regexp.Regexp, params Params) (string, error) {
+	switch typ {
+	case _PATTERN_STATIC:
+		return rawPattern, nil
+	case _PATTERN_MATCH_ALL:
+		val, ok := params["*"]
+		if !ok {
+			return "", fmt.Errorf("missing param: *")
+		}
+		return escapePath(val), nil
+	case _PATTERN_PATH_EXT:
+		path, ok := params[":path"]
+		if !ok {
+			return "", fmt.Errorf("missing param: :path")
+		}
+		if ext := params[":ext"]; len(ext) > 0 {
+			path += "." + ext
+		}
+		return escapePath(path), nil
+	}
+
+	// _PATTERN_HODLER 和 _PATTERN_REGEXP, 按出现顺序替换通配符
+	var err error
+	i := 0
+	segment := wildcardPattern.ReplaceAllStringFunc(rawPattern, func(wildcard string) string {
+		if i >= len(wildcards) {
+			return wildcard
+		}
+		name := wildcards[i]
+		i++
+
+		val, ok := params[name]
+		if !ok || len(val) == 0 {
+			if err == nil {
+				err = fmt.Errorf("missing param: %s", name)
+			}
+			return ""
+		}
+		return val
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// 类型和正则的约束 要和路由匹配时一致
+	if reg != nil {
+		results := reg.FindStringSubmatch(segment)
+		if len(results)-1 != len(wildcards) {
+			return "", fmt.Errorf("segment %q does not match pattern %q", segment, pattern)
+		}
+
+		for j, name := range wildcards {
+			if results[j+1] != params[name] {
+				return "", fmt.Errorf("param %s=%q does not match pattern %q", name, params[name], pattern)
+			}
+		}
+	}
+
+	return escapePath(segment), nil
+}
+
+// 路由匹配时用 PathUnescape 解码, 这里要保证能还原回来
+func escapePath(p string) string {
+	parts := strings.Split(p, "/")
+	for i := range parts {
+		parts[i] = strings.Replace(url.PathEscape(parts[i]), "+", "%2B", -1)
+	}
+	return strings.Join(parts, "/")
+}
@@ -0,0 +1,145 @@
+package simple
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"runtime"
+	"strings"
+
+	"github.com/go-macaron/inject"
+)
+
+// 每个请求都会注入的类型
+var builtinTypes = []reflect.Type{
+	reflect.TypeOf((*Context)(nil)),
+	reflect.TypeOf((*http.ResponseWriter)(nil)).Elem(),
+	reflect.TypeOf((*http.Request)(nil)),
+}
+
+type providesHandler struct {
+	handler Handler
+	types   []reflect.Type
+}
+
+// 声明 handler 会注入哪些类型, Validate 时后面的 handler 可以依赖这些类型
+// 接口类型和 MapTo 一样传接口的指针, 例如 (*session.Store)(nil), 其他类型传这个类型的值
+//
+//	m.Use(Provides(func(ctx *Context) { ctx.Map(&User{}) }, (*User)(nil)))
+func Provides(h Handler, types ...interface{}) Handler {
+	p := &providesHandler{handler: h}
+	if inner, ok := h.(*providesHandler); ok {
+		p.handler = inner.handler
+		p.types = append(p.types, inner.types...)
+	}
+
+	for _, v := range types {
+		t := reflect.TypeOf(v)
+		if t == nil {
+			panic("provided type cannot be untyped nil")
+		}
+		if t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Interface {
+			t = t.Elem()
+		}
+		p.types = append(p.types, t)
+	}
+	return p
+}
+
+// 注册时记下 handler 的参数, 给 Validate 用
+type handlerInfo struct {
+	name     string
+	in       []reflect.Type
+	provides []reflect.Type
+}
+
+func newHandlerInfo(h Handler) handlerInfo {
+	var info handlerInfo
+	if b, ok := h.(*beforeHandler); ok {
+		h = b.handler
+	}
+	if p, ok := h.(*providesHandler); ok {
+		h, info.provides = p.handler, p.types
+	}
+
+	v := reflect.ValueOf(h)
+	if v.Kind() != reflect.Func {
+		return info
+	}
+
+	if fn := runtime.FuncForPC(v.Pointer()); fn != nil {
+		info.name = fn.Name()
+	}
+	for i := 0; i < v.Type().NumIn(); i++ {
+		info.in = append(info.in, v.Type().In(i))
+	}
+	return info
+}
+
+func newHandlerInfos(handlers []Handler) []handlerInfo {
+	infos := make([]handlerInfo, len(handlers))
+	for i, h := range handlers {
+		infos[i] = newHandlerInfo(h)
+	}
+	return infos
+}
+
+type routeInfo struct {
+	method   string
+	pattern  string
+	handlers []handlerInfo
+}
+
+// 检查所有中间件, 路由, NotFound, MethodNotAllowed 和 InternalServerError 的 handler 参数是否都能注入, 一次返回所有的问题
+// 参数类型必须是内置的, 已经 Map 到 Simple 上的, 或者由前面用 Provides 声明过的 handler 注入
+// 应该在所有路由注册完, Run 之前调用
+func (m *Simple) Validate() error {
+	var problems []string
+	check := func(inj inject.Injector, where string, info handlerInfo) {
+		for _, t := range info.in {
+			if !inj.GetVal(t).IsValid() {
+				problems = append(problems, fmt.Sprintf("%s: handler %s requires %s, which is neither mapped nor provided", where, info.name, t))
+			}
+		}
+
+		for _, t := range info.provides {
+			inj.Set(t, reflect.Zero(t))
+		}
+	}
+
+	base := inject.New()
+	base.SetParent(m)
+	for _, t := range builtinTypes {
+		base.Set(t, reflect.Zero(t))
+	}
+
+	for _, info := range m.middlewares {
+		check(base, "middleware", info)
+	}
+
+	// 每组 handler 各自从中间件之后开始检查
+	checkAll := func(where string, infos []handlerInfo) {
+		inj := inject.New()
+		inj.SetParent(base)
+		for _, info := range infos {
+			check(inj, where, info)
+		}
+	}
+
+	for _, route := range m.Router.routes {
+		checkAll(route.method+" "+route.pattern, route.handlers)
+	}
+	checkAll("NotFound", m.Router.notFoundInfo)
+	checkAll("MethodNotAllowed", m.Router.methodNotAllowedInfo)
+
+	// panic 时注入了 error, 路由里 Provides 的类型不一定有
+	errType := reflect.TypeOf((*error)(nil)).Elem()
+	base.Set(errType, reflect.Zero(errType))
+	checkAll("InternalServerError", m.Router.internalServerErrorInfo)
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(problems, "\n"))
+}
@@ -0,0 +1,57 @@
+package simple
+
+import (
+	"net/http"
+)
+
+// 把标准库风格的中间件转换成 Handler
+// mw 调用 next 时继续执行后面的 handler, 不调用时后面的 handler 都不执行
+// 传给 next 的 *http.Request 和 http.ResponseWriter 会替换 Context 里的,
+// 例如 req.WithContext 加上的值, 后面的 handler 都能拿到
+//
+//	m.Use(WrapMiddleware(cors.Default().Handler))
+func WrapMiddleware(mw func(http.Handler) http.Handler) Handler {
+	return func(ctx *Context) {
+		called := false
+		next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			called = true
+			if req != ctx.Req.Request {
+				ctx.setRequest(req)
+			}
+
+			resp := ctx.Resp
+			if !sameResponseWriter(rw, resp) {
+				w, ok := rw.(ResponseWriter)
+				if !ok {
+					w = NewResponseWriter(req.Method, rw)
+				}
+				ctx.setResponseWriter(w)
+			}
+
+			ctx.Next()
+
+			// 外层的中间件继续使用原来的
+			if !sameResponseWriter(ctx.Resp, resp) {
+				ctx.setResponseWriter(resp)
+			}
+		})
+
+		mw(next).ServeHTTP(ctx.Resp, ctx.Req.Request)
+
+		if !called {
+			ctx.index = len(ctx.handlers)
+		}
+	}
+}
+
+// 接口比较时动态类型不能比较会 panic, 只比较 *responseWriter 的指针
+// 其他类型当作不同的, 多替换一次没有影响
+func sameResponseWriter(rw http.ResponseWriter, resp ResponseWriter) bool {
+	w, ok := rw.(*responseWriter)
+	if !ok {
+		return false
+	}
+
+	r, ok := resp.(*responseWriter)
+	return ok && w == r
+}